@@ -3,9 +3,14 @@ package main
 import (
 	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/pgxstore"
@@ -27,7 +32,9 @@ func main() {
 		panic(err)
 	}
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	pool, err := pgxpool.New(ctx, fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s",
 		os.Getenv("GOBID_DATABASE_USER"),
 		os.Getenv("GOBID_DATABASE_PASSWORD"),
@@ -56,20 +63,150 @@ func main() {
 
 	api := api.Api{
 		Router:         chi.NewMux(),
-		UserService:    services.NewUserService(pool),
-		ProductService: services.NewProductService(pool),
-		BidsService:    services.NewBidService(pool),
-		Sessions:       s,
-		WsUpgrader:     websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}, // true only for development
-		AuctionLobby:   services.AuctionLobby{Rooms: make(map[uuid.UUID]*services.AuctionRoom)},
+		UserService:    services.NewUserService(pool, services.LogMailer{}),
+		ProductService: services.NewProductService(pool, services.DefaultIncrementLadder),
+		BidsService: services.NewBidService(pool, services.SoftClose{
+			Window:    durationFromEnv("GOBID_SOFT_CLOSE_WINDOW", 2*time.Minute),
+			Extension: durationFromEnv("GOBID_SOFT_CLOSE_EXTENSION", 2*time.Minute),
+		}, services.DefaultIncrementLadder),
+		SettlementService: services.NewSettlementService(pool),
+		Sessions:          s,
+		WsUpgrader: websocket.Upgrader{
+			Subprotocols: services.Subprotocols,
+			CheckOrigin:  func(r *http.Request) bool { return true }, // true only for development
+		},
+		AuctionLobby: services.AuctionLobby{Rooms: make(map[uuid.UUID]*services.AuctionRoom)},
+		EventBus:     services.NewAuctionEventBus(pool),
+		RoomOptions: services.RoomOptions{
+			MaxSpectators:    intFromEnv("GOBID_MAX_SPECTATORS_PER_ROOM", 1000),
+			TimeSyncInterval: durationFromEnv("GOBID_TIME_SYNC_INTERVAL", 15*time.Second),
+			ClosingPhases:    durationsFromEnv("GOBID_CLOSING_PHASES", services.DefaultClosingPhases),
+		},
+	}
+
+	go api.EventBus.Run(ctx)
+
+	// An auction that cannot be settled now is retried on the next start and
+	// must not keep the others from running.
+	if err := api.SettlementService.SettleEndedAuctions(ctx); err != nil {
+		fmt.Println("Failed to settle some ended auctions:", err)
+	}
+
+	if err := api.RestoreAuctionRooms(ctx); err != nil {
+		fmt.Println("Failed to restore auction rooms")
+		panic(err)
 	}
 
 	api.BindRoutes()
 
-	fmt.Println("Server running on port :3080")
+	server := &http.Server{
+		Addr:    "localhost:3080",
+		Handler: api.Router,
+	}
+
+	go func() {
+		fmt.Println("Server running on port :3080")
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println("Failed to start server")
+			panic(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+
+	fmt.Println("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), durationFromEnv("GOBID_SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+
+	// Rooms are closed first, so their event streams end and Shutdown does
+	// not wait for them. Rooms started by requests still being served are
+	// shut down as they start.
+	api.AuctionLobby.CloseRooms()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("Failed to shut down server gracefully:", err)
+	}
+
+	if err := api.AuctionLobby.Wait(shutdownCtx); err != nil {
+		fmt.Println("Failed to close auction rooms gracefully:", err)
+	}
+
+	closePool(shutdownCtx, pool)
+}
+
+// closePool waits for the connections in use, e.g. by bid transactions, to
+// be released, unless ctx is done first.
+func closePool(ctx context.Context, pool *pgxpool.Pool) {
+	closed := make(chan struct{})
+
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		fmt.Println("Timed out waiting for database connections to be released")
+	}
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
 
-	if err := http.ListenAndServe("localhost:3080", api.Router); err != nil {
-		fmt.Println("Failed to start server")
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+
+	if err != nil {
+		fmt.Printf("Invalid duration for %s\n", key)
 		panic(err)
 	}
+
+	return duration
+}
+
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+
+	if err != nil {
+		fmt.Printf("Invalid number for %s\n", key)
+		panic(err)
+	}
+
+	return number
+}
+
+func durationsFromEnv(key string, fallback []time.Duration) []time.Duration {
+	value := os.Getenv(key)
+
+	if value == "" {
+		return fallback
+	}
+
+	var durations []time.Duration
+
+	for _, field := range strings.Split(value, ",") {
+		duration, err := time.ParseDuration(strings.TrimSpace(field))
+
+		if err != nil {
+			fmt.Printf("Invalid duration list for %s\n", key)
+			panic(err)
+		}
+
+		durations = append(durations, duration)
+	}
+
+	return durations
 }
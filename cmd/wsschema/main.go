@@ -0,0 +1,21 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/andresilvase/gobid/internal/services"
+)
+
+// wsschema prints the JSON Schema of the websocket protocol.
+func main() {
+	schema, err := json.MarshalIndent(services.ProtocolSchema(), "", "  ")
+
+	if err != nil {
+		fmt.Println("Failed to generate the protocol schema")
+		panic(err)
+	}
+
+	os.Stdout.Write(append(schema, '\n'))
+}
@@ -8,11 +8,14 @@ import (
 )
 
 type Api struct {
-	Router         *chi.Mux
-	UserService    services.UserService
-	ProductService services.ProductService
-	BidsService    services.BidsService
-	Sessions       *scs.SessionManager
-	WsUpgrader     websocket.Upgrader
-	AuctionLobby   services.AuctionLobby
+	Router            *chi.Mux
+	UserService       services.UserService
+	ProductService    services.ProductService
+	BidsService       services.BidsService
+	SettlementService services.SettlementService
+	Sessions          *scs.SessionManager
+	WsUpgrader        websocket.Upgrader
+	AuctionLobby      services.AuctionLobby
+	EventBus          *services.AuctionEventBus
+	RoomOptions       services.RoomOptions
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/andresilvase/gobid/internal/jsonutils"
 	"github.com/andresilvase/gobid/internal/services"
@@ -11,8 +12,40 @@ import (
 	"github.com/google/uuid"
 )
 
-func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Request) {
-	slog.Info("Received WebSocket connection request for", "product_id", chi.URLParam(r, "product_id"))
+func (api *Api) handleGetProtocolSchema(w http.ResponseWriter, r *http.Request) {
+	jsonutils.EncodeJson(w, r, http.StatusOK, services.ProtocolSchema())
+}
+
+// handleGetAuctionRoomStats reports the slow-client counters of the rooms
+// running on this instance for the auctions of the logged in seller.
+func (api *Api) handleGetAuctionRoomStats(w http.ResponseWriter, r *http.Request) {
+	sellerId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return
+	}
+
+	api.AuctionLobby.Lock()
+	stats := make(map[uuid.UUID]services.RoomStats)
+	for productId, room := range api.AuctionLobby.Rooms {
+		if room.SellerId == sellerId {
+			stats[productId] = room.Stats()
+		}
+	}
+	api.AuctionLobby.Unlock()
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
+		"rooms": stats,
+	})
+}
+
+// auctionRoom finds the running room of the auction in the product_id URL
+// parameter, starting it if the auction is open but has no room on this
+// instance yet. It writes the error response itself.
+func (api *Api) auctionRoom(w http.ResponseWriter, r *http.Request) (*services.AuctionRoom, bool) {
 	rawProductId := chi.URLParam(r, "product_id")
 
 	productId, err := uuid.Parse(rawProductId)
@@ -22,10 +55,10 @@ func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Requ
 		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
 			"message": "invalid product id - must be a valid uuid",
 		})
-		return
+		return nil, false
 	}
 
-	_, err = api.ProductService.GetProductById(r.Context(), productId)
+	product, err := api.ProductService.GetProductById(r.Context(), productId)
 
 	if err != nil {
 		slog.Error("", "", err)
@@ -33,33 +66,81 @@ func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Requ
 			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
 				"message": "product with given id not found",
 			})
-			return
+			return nil, false
 		}
 		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
 			"message": "unexpected internal server error",
 		})
+		return nil, false
+	}
+
+	api.AuctionLobby.Lock()
+	room, ok := api.AuctionLobby.Rooms[productId]
+	api.AuctionLobby.Unlock()
+
+	if !ok && isAuctionOpen(product) {
+		room, ok = api.startAuctionRoom(product), true
+	}
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "auction has ended"},
+		)
+		return nil, false
+	}
+
+	return room, true
+}
+
+// roomClosed answers a request that reached a room after it finished.
+func roomClosed(w http.ResponseWriter, r *http.Request, room *services.AuctionRoom) {
+	if room.Restarting() {
+		w.Header().Set("Retry-After", "5")
+		jsonutils.EncodeJson(w, r, http.StatusServiceUnavailable, map[string]any{
+			"message": "server is restarting, try again in a few seconds",
+		})
 		return
 	}
 
-	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+		"message": "auction has ended",
+	})
+}
+
+// parseSince reads the last event a reconnecting client saw, if any.
+func parseSince(w http.ResponseWriter, r *http.Request, rawSince string) (*int64, bool) {
+	if rawSince == "" {
+		return nil, true
+	}
+
+	seq, err := strconv.ParseInt(rawSince, 10, 64)
+
+	if err != nil || seq < 0 {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid since - must be a non-negative event sequence number",
+		})
+		return nil, false
+	}
+
+	return &seq, true
+}
+
+func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received WebSocket connection request for", "product_id", chi.URLParam(r, "product_id"))
+
+	userId, isLoggedIn := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
 	slog.Info("userId", "value", userId)
-	slog.Info("ok", "value", ok)
+	slog.Info("isLoggedIn", "value", isLoggedIn)
+
+	since, ok := parseSince(w, r, r.URL.Query().Get("since"))
 
 	if !ok {
-		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
-			"message": "unexpected internal server error, try logging in again",
-		})
 		return
 	}
 
-	api.AuctionLobby.Lock()
-	room, ok := api.AuctionLobby.Rooms[productId]
-	defer api.AuctionLobby.Unlock()
+	room, ok := api.auctionRoom(w, r)
 
 	if !ok {
-		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
-			"message": "auction has ended"},
-		)
 		return
 	}
 
@@ -72,9 +153,17 @@ func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	client := services.NewClient(room, conn, userId)
+	client := services.NewSpectator(room, conn, since)
+	if isLoggedIn {
+		client = services.NewClient(room, conn, userId, since)
+	}
 
-	room.Register <- client
+	select {
+	case room.Register <- client:
+	case <-room.Finished:
+		conn.Close()
+		return
+	}
 
 	go client.ReadEventLoop()
 	go client.WriteEventLoop()
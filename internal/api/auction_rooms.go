@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/services"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+)
+
+// startAuctionRoom runs the room of product on this instance, unless it is
+// already running.
+func (api *Api) startAuctionRoom(product pgstore.Product) *services.AuctionRoom {
+	api.AuctionLobby.Lock()
+	defer api.AuctionLobby.Unlock()
+
+	if auctionRoom, ok := api.AuctionLobby.Rooms[product.ID]; ok {
+		return auctionRoom
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	auctionRoom := services.NewAuctionRoom(ctx, product, api.BidsService, api.SettlementService, api.EventBus, api.RoomOptions)
+	api.AuctionLobby.Rooms[product.ID] = auctionRoom
+
+	if api.AuctionLobby.ShuttingDown {
+		api.AuctionLobby.ShutdownRoom(auctionRoom)
+	}
+
+	go func() {
+		defer cancel()
+		auctionRoom.Run()
+
+		api.AuctionLobby.Lock()
+		if api.AuctionLobby.Rooms[product.ID] == auctionRoom {
+			delete(api.AuctionLobby.Rooms, product.ID)
+		}
+		api.AuctionLobby.Unlock()
+	}()
+
+	return auctionRoom
+}
+
+// isAuctionOpen reports whether product can still receive bids, e.g. when
+// it was created on another instance and has no room here yet.
+func isAuctionOpen(product pgstore.Product) bool {
+	return !product.IsSold && !product.CancelledAt.Valid && product.AuctionEnd.After(time.Now())
+}
+
+// RestoreAuctionRooms registers a room for every auction that is still open
+// in the database, so a restart does not end live auctions.
+func (api *Api) RestoreAuctionRooms(ctx context.Context) error {
+	products, err := api.ProductService.ListOpenAuctions(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		api.startAuctionRoom(product)
+	}
+
+	slog.Info("Auction rooms restored", "count", len(products))
+
+	return nil
+}
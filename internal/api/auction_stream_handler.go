@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/jsonutils"
+	"github.com/andresilvase/gobid/internal/services"
+	"github.com/andresilvase/gobid/internal/usecase/bid"
+	"github.com/google/uuid"
+)
+
+const (
+	streamKeepAlive = 30 * time.Second
+	bidReplyTimeout = 30 * time.Second
+)
+
+// handleStreamAuction follows an auction room with Server-Sent Events, for
+// clients that cannot keep a websocket open. Events use the envelopes of the
+// versioned websocket protocol; numbered ones carry their sequence as the
+// event id, so browsers resume with Last-Event-ID after reconnecting.
+func (api *Api) handleStreamAuction(w http.ResponseWriter, r *http.Request) {
+	rawSince := r.URL.Query().Get("since")
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		rawSince = lastEventId
+	}
+
+	since, ok := parseSince(w, r, rawSince)
+
+	if !ok {
+		return
+	}
+
+	room, ok := api.auctionRoom(w, r)
+
+	if !ok {
+		return
+	}
+
+	client := services.NewSpectator(room, nil, since)
+	if userId, isLoggedIn := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID); isLoggedIn {
+		client = services.NewClient(room, nil, userId, since)
+	}
+
+	select {
+	case room.Register <- client:
+	case <-room.Finished:
+		roomClosed(w, r, room)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	defer client.Unregister()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The session middleware wraps w, so flushing goes through a controller
+	// that unwraps it.
+	flusher := http.NewResponseController(w)
+
+	if err := flusher.Flush(); err != nil {
+		slog.Error("Failed to start auction stream", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case message, ok := <-client.Send:
+			if !ok {
+				fmt.Fprintf(w, "event: close\ndata: %q\n\n", client.CloseReason())
+				flusher.Flush()
+				return
+			}
+
+			if err := writeStreamEvent(w, client.Encode(message), message); err != nil {
+				slog.Error("Failed to write auction stream event", "error", err)
+				return
+			}
+			flusher.Flush()
+
+			if message.Kind == services.AuctionFinished || message.Kind == services.AuctionCancelled {
+				return
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, envelope any, message services.Message) error {
+	data, err := json.Marshal(envelope)
+
+	if err != nil {
+		return err
+	}
+
+	// EventSource reconnects on its own; make it wait as long as the
+	// server asks.
+	if message.ReconnectAfterMs > 0 {
+		if _, err := fmt.Fprintf(w, "retry: %d\n", message.ReconnectAfterMs); err != nil {
+			return err
+		}
+	}
+
+	if message.Seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", message.Seq); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Kind, data)
+	return err
+}
+
+// requestRoom sends a request of the logged in user to the room of the
+// auction in the product_id URL parameter, like a websocket client would,
+// and waits for the room to answer it. It writes the error response itself.
+func (api *Api) requestRoom(w http.ResponseWriter, r *http.Request, request services.Message) (services.Message, bool) {
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return services.Message{}, false
+	}
+
+	room, ok := api.auctionRoom(w, r)
+
+	if !ok {
+		return services.Message{}, false
+	}
+
+	reply := make(chan services.Message, 1)
+	request.UserId = userId
+	request.Reply = reply
+
+	timeout := time.NewTimer(bidReplyTimeout)
+	defer timeout.Stop()
+
+	select {
+	case room.Broadcast <- request:
+	case <-room.Finished:
+		roomClosed(w, r, room)
+		return services.Message{}, false
+	case <-r.Context().Done():
+		return services.Message{}, false
+	}
+
+	select {
+	case response := <-reply:
+		return response, true
+	case <-room.Finished:
+		// The room answers before finishing, so the reply may be there.
+		select {
+		case response := <-reply:
+			return response, true
+		default:
+			roomClosed(w, r, room)
+			return services.Message{}, false
+		}
+	case <-timeout.C:
+		jsonutils.EncodeJson(w, r, http.StatusGatewayTimeout, map[string]any{
+			"message": "the auction did not answer in time, try again later",
+		})
+		return services.Message{}, false
+	case <-r.Context().Done():
+		return services.Message{}, false
+	}
+}
+
+// handlePlaceBid places a bid over REST. The bid goes through the auction
+// room like a websocket bid, so every connected client hears about it.
+func (api *Api) handlePlaceBid(w http.ResponseWriter, r *http.Request) {
+	data, problems, err := jsonutils.DecodeValidJson[bid.PlaceBidReq](r)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+		return
+	}
+
+	response, ok := api.requestRoom(w, r, services.Message{
+		Kind:      services.PlaceBid,
+		Amount:    data.Amount,
+		MaxAmount: data.MaxAmount,
+	})
+
+	if !ok {
+		return
+	}
+
+	if response.Kind != services.SuccessfullyPlacedBid {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+			"message":          response.Message,
+			"minimum_next_bid": response.MinimumNextBid,
+		})
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
+		"message":          response.Message,
+		"amount":           response.Amount,
+		"max_amount":       response.MaxAmount,
+		"reserve_met":      response.ReserveMet,
+		"minimum_next_bid": response.MinimumNextBid,
+	})
+}
+
+// handleBuyNow buys the product of an english auction at its buy now price
+// over REST, through the auction room like handlePlaceBid.
+func (api *Api) handleBuyNow(w http.ResponseWriter, r *http.Request) {
+	response, ok := api.requestRoom(w, r, services.Message{Kind: services.BuyNow})
+
+	if !ok {
+		return
+	}
+
+	purchaseResponse(w, r, response, services.SuccessfullyBoughtNow)
+}
+
+// handleAcceptPrice buys the product of a dutch auction at its current
+// asking price over REST, through the auction room like handlePlaceBid.
+func (api *Api) handleAcceptPrice(w http.ResponseWriter, r *http.Request) {
+	response, ok := api.requestRoom(w, r, services.Message{Kind: services.AcceptPrice})
+
+	if !ok {
+		return
+	}
+
+	purchaseResponse(w, r, response, services.SuccessfullyAcceptedPrice)
+}
+
+// purchaseResponse answers a request that buys the product outright.
+func purchaseResponse(w http.ResponseWriter, r *http.Request, response services.Message, success services.Messagekind) {
+	if response.Kind != success {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+			"message": response.Message,
+		})
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
+		"message": response.Message,
+		"price":   response.Amount,
+	})
+}
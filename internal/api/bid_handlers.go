@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/andresilvase/gobid/internal/jsonutils"
+	"github.com/andresilvase/gobid/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBidsPerPage = 50
+	maxBidsPerPage     = 100
+)
+
+// handleGetProductBids lists the bids of an auction newest first. Bidders
+// are shown by their alias in the auction; a logged in viewer sees which
+// bids are theirs.
+func (api *Api) handleGetProductBids(w http.ResponseWriter, r *http.Request) {
+	productId, err := uuid.Parse(chi.URLParam(r, "product_id"))
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	limit := defaultBidsPerPage
+
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		limit, err = strconv.Atoi(rawLimit)
+
+		if err != nil || limit <= 0 || limit > maxBidsPerPage {
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"limit": "must be between 1 and 100",
+			})
+			return
+		}
+	}
+
+	viewerId, _ := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	page, err := api.BidsService.BidHistory(r.Context(), productId, viewerId, r.URL.Query().Get("cursor"), int32(limit))
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrProductNotFound):
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "product with given id not found",
+			})
+		case errors.Is(err, services.ErrBidsHidden):
+			jsonutils.EncodeJson(w, r, http.StatusForbidden, map[string]any{
+				"message": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidCursor):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"cursor": "invalid cursor - pass the next_cursor of the previous page",
+			})
+		default:
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"error": "unexpected error, try again later",
+			})
+		}
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, page)
+}
+
+// handleListMyBids lists every auction the logged in user bid on, with
+// their highest bid and whether they are winning, outbid, won or lost.
+func (api *Api) handleListMyBids(w http.ResponseWriter, r *http.Request) {
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return
+	}
+
+	auctions, err := api.BidsService.BidderAuctions(r.Context(), userId)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
+		"auctions": auctions,
+	})
+}
@@ -1,14 +1,24 @@
 package api
 
 import (
-	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/andresilvase/gobid/internal/jsonutils"
+	"github.com/andresilvase/gobid/internal/money"
 	"github.com/andresilvase/gobid/internal/services"
 	"github.com/andresilvase/gobid/internal/store/pgstore"
 	"github.com/andresilvase/gobid/internal/usecase/product"
+	"github.com/andresilvase/gobid/internal/validator"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func (api *Api) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
@@ -28,15 +38,39 @@ func (api *Api) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product_id, err := api.ProductService.CreateProduct(r.Context(),
-		pgstore.CreateProductParams{
-			SellerID:    userId,
-			ProductName: data.ProductName,
-			Description: data.Description,
-			Baseprice:   data.Baseprice,
-			AuctionEnd:  data.AuctionEnd,
-		},
-	)
+	params := pgstore.CreateProductParams{
+		SellerID:    userId,
+		ProductName: data.ProductName,
+		Description: data.Description,
+		Baseprice:   data.Baseprice.Amount,
+		AuctionEnd:  data.AuctionEnd,
+		Currency:    data.Baseprice.Currency,
+		AuctionType: data.AuctionType,
+	}
+
+	if data.ReservePrice != nil {
+		params.ReservePrice = &data.ReservePrice.Amount
+	}
+
+	if data.BuyNowPrice != nil {
+		params.BuyNowPrice = &data.BuyNowPrice.Amount
+	}
+
+	if data.BidIncrement != nil {
+		params.BidIncrement = &data.BidIncrement.Amount
+	}
+
+	if data.BidIncrementBps != nil {
+		params.BidIncrementBps = pgtype.Int4{Int32: *data.BidIncrementBps, Valid: true}
+	}
+
+	if data.AuctionType == pgstore.AuctionTypeDutch {
+		params.DutchFloorPrice = &data.DutchFloorPrice.Amount
+		params.DutchPriceDrop = &data.DutchPriceDrop.Amount
+		params.DutchDropIntervalSeconds = pgtype.Int4{Int32: *data.DutchDropIntervalSeconds, Valid: true}
+	}
+
+	product_id, err := api.ProductService.CreateProduct(r.Context(), params)
 
 	if err != nil {
 		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
@@ -45,19 +79,198 @@ func (api *Api) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, _ := context.WithDeadline(context.Background(), data.AuctionEnd)
+	product, err := api.ProductService.GetProductById(r.Context(), product_id)
 
-	auctionRoom := services.NewAuctionRoom(ctx, product_id, api.BidsService)
-
-	go auctionRoom.Run()
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "failed to start product auction, try again later",
+		})
+		return
+	}
 
-	api.AuctionLobby.Lock()
-	api.AuctionLobby.Rooms[product_id] = auctionRoom
-	api.AuctionLobby.Unlock()
+	api.startAuctionRoom(product)
 
-	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
+	response := map[string]any{
 		"message":    "Auction has started successfully",
 		"product_id": product_id,
-	})
+	}
+
+	// The product exists by now, so failing to read the minimum next bid
+	// only leaves it out of the response.
+	minimumNextBid, err := api.BidsService.MinimumNextBid(r.Context(), product_id)
+
+	if err != nil {
+		slog.Error("Failed to read minimum next bid", "productID", product_id, "error", err)
+	} else {
+		response["minimum_next_bid"] = minimumNextBid
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, response)
+
+}
+
+const (
+	defaultProductsPerPage = 20
+	maxProductsPerPage     = 100
+	// allProducts lists products whatever their status.
+	allProducts     = "all"
+	maxSearchLength = 200
+)
+
+var (
+	productStatuses = []string{services.ProductStatusActive, services.ProductStatusEnded, services.ProductStatusSold, services.ProductStatusCancelled, allProducts}
+	productSorts    = []string{services.SortEndingSoonest, services.SortNewest, services.SortMostBids, services.SortHighestPrice}
+)
+
+// parseProductFilter reads the query string of a product listing. Only
+// active auctions are listed unless asked otherwise, ending soonest first,
+// or best matches first when searching.
+func parseProductFilter(query url.Values, searching bool) (services.ProductFilter, validator.Evaluator) {
+	var val validator.Evaluator
+
+	filter := services.ProductFilter{
+		Status: services.ProductStatusActive,
+		Sort:   services.SortEndingSoonest,
+		Cursor: query.Get("cursor"),
+		Limit:  defaultProductsPerPage,
+	}
+
+	sorts := productSorts
+
+	if searching {
+		filter.Search = query.Get("q")
+		filter.Sort = services.SortRelevance
+		sorts = append(slices.Clip(sorts), services.SortRelevance)
+
+		val.CheckField(validator.NotBlank(filter.Search), "q", "this field is required")
+		val.CheckField(validator.MaxChars(filter.Search, maxSearchLength), "q", "must have at most 200 characters")
+	}
+
+	if status := query.Get("status"); status != "" {
+		val.CheckField(slices.Contains(productStatuses, status), "status", "must be one of active, ended, sold, cancelled or all")
+		filter.Status = status
+	}
+
+	if filter.Status == allProducts {
+		filter.Status = ""
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		val.CheckField(slices.Contains(sorts, sort), "sort", "must be one of "+strings.Join(sorts, ", "))
+		filter.Sort = sort
+	}
+
+	if rawSellerId := query.Get("seller_id"); rawSellerId != "" {
+		sellerId, err := uuid.Parse(rawSellerId)
+		val.CheckField(err == nil, "seller_id", "must be a valid uuid")
+		filter.SellerId = &sellerId
+	}
+
+	if rawEndingBefore := query.Get("ending_before"); rawEndingBefore != "" {
+		endingBefore, err := time.Parse(time.RFC3339, rawEndingBefore)
+		val.CheckField(err == nil, "ending_before", "must be an RFC 3339 timestamp")
+		filter.EndingBefore = &endingBefore
+	}
+
+	currency := query.Get("currency")
+	filter.MinPrice = parsePriceFilter(&val, "min_price", query.Get("min_price"), currency)
+	filter.MaxPrice = parsePriceFilter(&val, "max_price", query.Get("max_price"), currency)
+
+	if filter.MinPrice != nil && filter.MaxPrice != nil {
+		val.CheckField(filter.MinPrice.Amount <= filter.MaxPrice.Amount, "max_price", "must be greater than or equal to min_price")
+	}
+
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		val.CheckField(err == nil && limit > 0 && limit <= maxProductsPerPage, "limit", "must be between 1 and 100")
+		filter.Limit = int32(limit)
+	}
+
+	return filter, val
+}
+
+func parsePriceFilter(val *validator.Evaluator, key, rawPrice, currency string) *money.Money {
+	if rawPrice == "" {
+		return nil
+	}
+
+	if !money.IsSupportedCurrency(currency) {
+		val.AddFieldError("currency", "a supported currency is required to filter by price")
+		return nil
+	}
+
+	price, err := money.Parse(rawPrice, currency)
+	val.CheckField(err == nil, key, "must be a decimal amount, e.g. 1250.90")
+
+	return &price
+}
+
+func (api *Api) handleListProducts(w http.ResponseWriter, r *http.Request) {
+	api.listProducts(w, r, false)
+}
+
+// handleSearchProducts looks for q in product names and descriptions. Words
+// in double quotes match as a phrase and a word ending in * matches by
+// prefix; the listing filters apply as well.
+func (api *Api) handleSearchProducts(w http.ResponseWriter, r *http.Request) {
+	api.listProducts(w, r, true)
+}
+
+func (api *Api) listProducts(w http.ResponseWriter, r *http.Request, searching bool) {
+	filter, problems := parseProductFilter(r.URL.Query(), searching)
+
+	if len(problems) > 0 {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+		return
+	}
+
+	page, err := api.ProductService.ListProducts(r.Context(), filter)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCursor):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"cursor": "invalid cursor - pass the next_cursor of the previous page with the same sort",
+			})
+		case errors.Is(err, services.ErrEmptySearch):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"q": "must contain at least one word",
+			})
+		default:
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"error": "unexpected error, try again later",
+			})
+		}
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, page)
+}
+
+func (api *Api) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	productId, err := uuid.Parse(chi.URLParam(r, "product_id"))
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	listing, err := api.ProductService.GetProductListing(r.Context(), productId)
+
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "product with given id not found",
+			})
+			return
+		}
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return
+	}
 
+	jsonutils.EncodeJson(w, r, http.StatusOK, listing)
 }
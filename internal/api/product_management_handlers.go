@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/andresilvase/gobid/internal/jsonutils"
+	"github.com/andresilvase/gobid/internal/services"
+	"github.com/andresilvase/gobid/internal/usecase/product"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// sellerRequest reads the product_id URL parameter and the logged in user of
+// a request that manages a product. It writes the error response itself.
+func (api *Api) sellerRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	productId, err := uuid.Parse(chi.URLParam(r, "product_id"))
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return productId, userId, true
+}
+
+// productManagementError answers a seller action the product does not allow.
+func productManagementError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalidEdit *services.InvalidProductEditError
+
+	switch {
+	case errors.As(err, &invalidEdit):
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, invalidEdit.Problems)
+	case errors.Is(err, services.ErrProductNotFound):
+		jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+			"message": "product with given id not found",
+		})
+	case errors.Is(err, services.ErrNotSeller):
+		jsonutils.EncodeJson(w, r, http.StatusForbidden, map[string]any{
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrProductHasBids),
+		errors.Is(err, services.ErrAuctionEnded),
+		errors.Is(err, services.ErrAuctionCancelled),
+		errors.Is(err, services.ErrNotRelistable),
+		errors.Is(err, services.ErrAlreadyRelisted),
+		errors.Is(err, services.ErrUnsupportedForAuctionType):
+		jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+			"message": err.Error(),
+		})
+	default:
+		slog.Error("Failed to manage product", "error", err)
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+	}
+}
+
+// notifyAuction passes a seller's change to the room of the auction, or to
+// its rooms on other instances when it has none here.
+func (api *Api) notifyAuction(ctx context.Context, productId uuid.UUID, notify func(*services.AuctionRoom), message services.Message) {
+	api.AuctionLobby.Lock()
+	room, ok := api.AuctionLobby.Rooms[productId]
+	api.AuctionLobby.Unlock()
+
+	if ok {
+		notify(room)
+		return
+	}
+
+	if api.EventBus == nil {
+		return
+	}
+
+	err := api.EventBus.Publish(ctx, services.AuctionEvent{
+		Id:        uuid.New(),
+		ProductId: productId,
+		Message:   message,
+	})
+
+	if err != nil {
+		slog.Error("Failed to publish auction event", "auctionID", productId, "error", err)
+	}
+}
+
+// handleUpdateProduct edits a product of the logged in seller. The
+// description can be changed at any time, everything else only until the
+// first bid.
+func (api *Api) handleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	productId, sellerId, ok := api.sellerRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	data, problems, err := jsonutils.DecodeValidJson[product.UpdateProductReq](r)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+		return
+	}
+
+	updated, err := api.ProductService.UpdateProduct(r.Context(), productId, sellerId, services.ProductEdit{
+		ProductName:     data.ProductName,
+		Description:     data.Description,
+		Baseprice:       data.Baseprice,
+		ReservePrice:    data.ReservePrice,
+		BuyNowPrice:     data.BuyNowPrice,
+		BidIncrement:    data.BidIncrement,
+		BidIncrementBps: data.BidIncrementBps,
+		AuctionEnd:      data.AuctionEnd,
+	})
+
+	if err != nil {
+		productManagementError(w, r, err)
+		return
+	}
+
+	auctionEnd := updated.AuctionEnd
+
+	if isAuctionOpen(updated) {
+		api.notifyAuction(r.Context(), productId, func(room *services.AuctionRoom) {
+			room.Update(auctionEnd)
+		}, services.Message{
+			Kind:       services.AuctionUpdated,
+			Message:    "The seller has updated the auction",
+			AuctionEnd: &auctionEnd,
+		})
+	}
+
+	listing, err := api.ProductService.GetProductListing(r.Context(), productId)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, listing)
+}
+
+// handleCancelProduct withdraws an open auction of the logged in seller and
+// closes its rooms.
+func (api *Api) handleCancelProduct(w http.ResponseWriter, r *http.Request) {
+	productId, sellerId, ok := api.sellerRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	if err := api.ProductService.CancelProduct(r.Context(), productId, sellerId); err != nil {
+		productManagementError(w, r, err)
+		return
+	}
+
+	api.notifyAuction(r.Context(), productId, (*services.AuctionRoom).Cancel, services.Message{
+		Kind:    services.AuctionCancelled,
+		Message: "The seller has cancelled the auction",
+	})
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
+		"message": "Auction has been cancelled",
+	})
+}
+
+// handleRelistProduct starts a new auction of a product of the logged in
+// seller that was cancelled or ended unsold.
+func (api *Api) handleRelistProduct(w http.ResponseWriter, r *http.Request) {
+	productId, sellerId, ok := api.sellerRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	data, problems, err := jsonutils.DecodeValidJson[product.RelistProductReq](r)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+		return
+	}
+
+	relistedId, err := api.ProductService.RelistProduct(r.Context(), productId, sellerId, data.AuctionEnd)
+
+	if err != nil {
+		productManagementError(w, r, err)
+		return
+	}
+
+	relisted, err := api.ProductService.GetProductById(r.Context(), relistedId)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "failed to start product auction, try again later",
+		})
+		return
+	}
+
+	api.startAuctionRoom(relisted)
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
+		"message":       "Auction has been relisted successfully",
+		"product_id":    relistedId,
+		"relisted_from": productId,
+	})
+}
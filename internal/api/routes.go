@@ -23,20 +23,42 @@ func (api *Api) BindRoutes() {
 			r.Route("/users", func(r chi.Router) {
 				r.Post("/signup", api.handleSignupUser)
 				r.Post("/login", api.handleLoginUser)
+				r.Get("/{user_name}", api.handleGetPublicProfile)
 
 				r.Group(func(r chi.Router) {
 					r.Use(api.AuthMiddleware)
 					r.Post("/logout", api.handleLogoutUser)
+					r.Get("/me", api.handleGetMe)
+					r.Patch("/me", api.handleUpdateMe)
+					r.Post("/me/email/verify", api.handleVerifyEmail)
+					r.Get("/me/bids", api.handleListMyBids)
 				})
 			})
 
+			r.Get("/ws/schema", api.handleGetProtocolSchema)
+
 			r.Route("/products", func(r chi.Router) {
+				r.Get("/", api.handleListProducts)
+				r.Get("/search", api.handleSearchProducts)
+				r.Get("/{product_id}", api.handleGetProduct)
+				// Logged out visitors subscribe as spectators.
+				r.Get("/ws/subscribe/{product_id}", func(w http.ResponseWriter, r *http.Request) {
+					api.handleSubscribeUserToAuction(w, r)
+				})
+				r.Get("/{product_id}/events", api.handleStreamAuction)
+				r.Get("/{product_id}/bids", api.handleGetProductBids)
+
 				r.Group(func(r chi.Router) {
 					r.Use(api.AuthMiddleware)
 					r.Post("/", api.handleCreateProduct)
-					r.Get("/ws/subscribe/{product_id}", func(w http.ResponseWriter, r *http.Request) {
-						api.handleSubscribeUserToAuction(w, r)
-					})
+					r.Get("/ws/stats", api.handleGetAuctionRoomStats)
+					r.Post("/{product_id}/bids", api.handlePlaceBid)
+					r.Post("/{product_id}/buy_now", api.handleBuyNow)
+					r.Post("/{product_id}/accept_price", api.handleAcceptPrice)
+					r.Patch("/{product_id}", api.handleUpdateProduct)
+					r.Delete("/{product_id}", api.handleCancelProduct)
+					r.Post("/{product_id}/relist", api.handleRelistProduct)
+					r.Post("/{product_id}/rating", api.handleRateSeller)
 				})
 			})
 		})
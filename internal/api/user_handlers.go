@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
 	"github.com/andresilvase/gobid/internal/jsonutils"
 	"github.com/andresilvase/gobid/internal/services"
 	"github.com/andresilvase/gobid/internal/usecase/user"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 func (a *Api) handleSignupUser(w http.ResponseWriter, r *http.Request) {
@@ -70,7 +73,7 @@ func (a *Api) handleLoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.Sessions.RenewToken(r.Context())
+	err = a.startSession(r.Context(), id)
 
 	if err != nil {
 		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
@@ -80,15 +83,33 @@ func (a *Api) handleLoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.Sessions.Put(r.Context(), "AuthenticatedUserId", id)
-
 	jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
 		"message": "logged in successfully",
 	})
 
 }
 
+// startSession gives the session of the request a new token for userId and
+// tracks it, so it can be ended along with the user's other sessions.
+func (a *Api) startSession(ctx context.Context, userId uuid.UUID) error {
+	if err := a.Sessions.RenewToken(ctx); err != nil {
+		return err
+	}
+
+	a.Sessions.Put(ctx, "AuthenticatedUserId", userId)
+
+	return a.UserService.TrackSession(ctx, userId, a.Sessions.Token(ctx))
+}
+
 func (a *Api) handleLogoutUser(w http.ResponseWriter, r *http.Request) {
+	if err := a.UserService.ForgetSession(r.Context(), a.Sessions.Token(r.Context())); err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
 	err := a.Sessions.RenewToken(r.Context())
 
 	if err != nil {
@@ -105,3 +126,232 @@ func (a *Api) handleLogoutUser(w http.ResponseWriter, r *http.Request) {
 		"message": "logged out successfully",
 	})
 }
+
+func (a *Api) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
+	account, err := a.UserService.GetAccount(r.Context(), userId)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, account)
+}
+
+// handleUpdateMe changes the username, bio, email or password of the logged
+// in user. A new email has to be verified before it replaces the current
+// one, see handleVerifyEmail.
+func (a *Api) handleUpdateMe(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
+	data, problems, err := jsonutils.DecodeValidJson[user.UpdateUserReq](r)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+
+		return
+	}
+
+	account, err := a.UserService.UpdateAccount(r.Context(), userId, services.AccountUpdate{
+		UserName:        data.UserName,
+		Bio:             data.Bio,
+		Email:           data.Email,
+		NewPassword:     data.NewPassword,
+		CurrentPassword: data.CurrentPassword,
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"current_password": "is incorrect",
+			})
+		case errors.Is(err, services.ErrDuplicateEmailOrUsername):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"error": "email or username already exists",
+			})
+		default:
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"error": "unexpected internal server error",
+			})
+		}
+
+		return
+	}
+
+	if data.NewPassword != nil {
+		// The password change ended every session of the user; this one
+		// goes on with a new token like it does when logging in.
+		if err := a.startSession(r.Context(), userId); err != nil {
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"error": "unexpected internal server error",
+			})
+
+			return
+		}
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, account)
+}
+
+func (a *Api) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	userId, ok := a.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
+	data, problems, err := jsonutils.DecodeValidJson[user.VerifyEmailReq](r)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+
+		return
+	}
+
+	account, err := a.UserService.VerifyEmail(r.Context(), userId, data.Token)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidEmailToken):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"token": err.Error(),
+			})
+		case errors.Is(err, services.ErrDuplicateEmailOrUsername):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"error": "email or username already exists",
+			})
+		default:
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"error": "unexpected internal server error",
+			})
+		}
+
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, account)
+}
+
+// handleGetPublicProfile shows what everyone may see of a user: their bio,
+// when they joined, how buyers rated them and their open auctions.
+func (a *Api) handleGetPublicProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := a.UserService.GetPublicProfile(r.Context(), chi.URLParam(r, "user_name"))
+
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "user with given username not found",
+			})
+
+			return
+		}
+
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
+	listings, err := a.ProductService.ListProducts(r.Context(), services.ProductFilter{
+		Status:   services.ProductStatusActive,
+		SellerId: &profile.Id,
+		Sort:     services.SortEndingSoonest,
+		Limit:    defaultProductsPerPage,
+	})
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
+	profile.ActiveListings = listings.Products
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, profile)
+}
+
+// handleRateSeller lets the winner of a sold auction rate its seller.
+func (a *Api) handleRateSeller(w http.ResponseWriter, r *http.Request) {
+	productId, err := uuid.Parse(chi.URLParam(r, "product_id"))
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+
+		return
+	}
+
+	userId, ok := a.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected internal server error",
+		})
+
+		return
+	}
+
+	data, problems, err := jsonutils.DecodeValidJson[user.RateSellerReq](r)
+
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+
+		return
+	}
+
+	rating, err := a.UserService.RateSeller(r.Context(), productId, userId, data.Score, data.Comment)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotRateable):
+			jsonutils.EncodeJson(w, r, http.StatusForbidden, map[string]any{
+				"message": err.Error(),
+			})
+		case errors.Is(err, services.ErrAlreadyRated):
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"message": err.Error(),
+			})
+		default:
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"error": "unexpected internal server error",
+			})
+		}
+
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
+		"score":   rating.Score,
+		"comment": rating.Comment,
+	})
+}
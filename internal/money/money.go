@@ -0,0 +1,171 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a quantity of money expressed in the minor unit of its currency,
+// e.g. cents for BRL or USD.
+type Amount int64
+
+// Money is an exact amount of a given ISO 4217 currency.
+type Money struct {
+	Amount   Amount
+	Currency string
+}
+
+const DefaultCurrency = "BRL"
+
+// minorUnits maps each supported currency to the number of decimal places of
+// its minor unit.
+var minorUnits = map[string]int{
+	"BRL": 2,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+}
+
+var ErrInvalidAmount = errors.New("invalid money amount")
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+func IsSupportedCurrency(currency string) bool {
+	_, ok := minorUnits[currency]
+	return ok
+}
+
+func New(amount Amount, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse reads a decimal string such as "1250.90" as an exact amount of the
+// given currency. Values with more decimal places than the currency supports
+// are rejected instead of rounded.
+func Parse(value, currency string) (Money, error) {
+	digits, ok := minorUnits[currency]
+
+	if !ok {
+		return Money{}, ErrUnsupportedCurrency
+	}
+
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, hasFraction := strings.Cut(value, ".")
+
+	if whole == "" || (hasFraction && fraction == "") || len(fraction) > digits {
+		return Money{}, ErrInvalidAmount
+	}
+
+	fraction += strings.Repeat("0", digits-len(fraction))
+
+	if strings.ContainsFunc(whole+fraction, func(r rune) bool { return r < '0' || r > '9' }) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+
+	if negative {
+		minor = -minor
+	}
+
+	return New(Amount(minor), currency), nil
+}
+
+// Decimal formats the amount in major units, e.g. "1250.90".
+func (m Money) Decimal() string {
+	digits := minorUnits[m.Currency]
+	minor := int64(m.Amount)
+
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+
+	abs := strconv.FormatUint(absUint(minor), 10)
+
+	if digits == 0 {
+		return sign + abs
+	}
+
+	if len(abs) <= digits {
+		abs = strings.Repeat("0", digits-len(abs)+1) + abs
+	}
+
+	return sign + abs[:len(abs)-digits] + "." + abs[len(abs)-digits:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string so clients never have to
+// go through a floating point number.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{
+		Amount:   m.Decimal(),
+		Currency: m.Currency,
+	})
+}
+
+// UnmarshalJSON accepts the amount either as a decimal string or as a JSON
+// number; numbers are parsed from their literal text, not as a float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: expected an object with amount and currency", ErrInvalidAmount)
+	}
+
+	if raw.Currency == "" {
+		raw.Currency = DefaultCurrency
+	}
+
+	parsed, err := Parse(raw.Amount.String(), strings.ToUpper(raw.Currency))
+
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+
+	return nil
+}
+
+func absUint(value int64) uint64 {
+	if value == math.MinInt64 {
+		return uint64(math.MaxInt64) + 1
+	}
+
+	if value < 0 {
+		return uint64(-value)
+	}
+
+	return uint64(value)
+}
@@ -0,0 +1,118 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     Amount
+		err      error
+	}{
+		{"1250.90", "BRL", 125090, nil},
+		{"1250.9", "BRL", 125090, nil},
+		{"1250", "BRL", 125000, nil},
+		{"0.01", "USD", 1, nil},
+		{"  7.50 ", "EUR", 750, nil},
+		{"-3.25", "GBP", -325, nil},
+		{"1000", "JPY", 1000, nil},
+		{"1000.5", "JPY", 0, ErrInvalidAmount},
+		{"1.005", "BRL", 0, ErrInvalidAmount},
+		{"1.", "BRL", 0, ErrInvalidAmount},
+		{".50", "BRL", 0, ErrInvalidAmount},
+		{"", "BRL", 0, ErrInvalidAmount},
+		{"1e3", "BRL", 0, ErrInvalidAmount},
+		{"+1", "BRL", 0, ErrInvalidAmount},
+		{"--1", "BRL", 0, ErrInvalidAmount},
+		{"1,50", "BRL", 0, ErrInvalidAmount},
+		{"99999999999999999999", "BRL", 0, ErrInvalidAmount},
+		{"10", "XYZ", 0, ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.value, tt.currency)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q, %q) error = %v, want %v", tt.value, tt.currency, err, tt.err)
+			continue
+		}
+
+		if err == nil && (got.Amount != tt.want || got.Currency != tt.currency) {
+			t.Errorf("Parse(%q, %q) = %v, want %d %s", tt.value, tt.currency, got, tt.want, tt.currency)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{New(125090, "BRL"), "1250.90"},
+		{New(1, "USD"), "0.01"},
+		{New(10, "USD"), "0.10"},
+		{New(0, "EUR"), "0.00"},
+		{New(-5, "GBP"), "-0.05"},
+		{New(-325, "GBP"), "-3.25"},
+		{New(1000, "JPY"), "1000"},
+		{New(-7, "JPY"), "-7"},
+		{New(math.MinInt64, "JPY"), "-9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("%#v.Decimal() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestParseDecimalRoundTrip(t *testing.T) {
+	for _, money := range []Money{New(125090, "BRL"), New(-1, "USD"), New(42, "JPY"), New(0, "EUR")} {
+		parsed, err := Parse(money.Decimal(), money.Currency)
+
+		if err != nil || parsed != money {
+			t.Errorf("Parse(%q) = %v, %v, want %v", money.Decimal(), parsed, err, money)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want Money
+		err  bool
+	}{
+		{`{"amount": "1250.90", "currency": "BRL"}`, New(125090, "BRL"), false},
+		{`{"amount": 1250.90, "currency": "usd"}`, New(125090, "USD"), false},
+		{`{"amount": 0.1, "currency": "EUR"}`, New(10, "EUR"), false},
+		{`{"amount": "10"}`, New(1000, DefaultCurrency), false},
+		{`{"amount": 1.001, "currency": "BRL"}`, Money{}, true},
+		{`{"amount": "ten", "currency": "BRL"}`, Money{}, true},
+		{`"10.00"`, Money{}, true},
+	}
+
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.data), &got)
+
+		if (err != nil) != tt.err {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", tt.data, err, tt.err)
+			continue
+		}
+
+		if err == nil && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+
+	data, err := json.Marshal(New(125090, "BRL"))
+
+	if err != nil || string(data) != `{"amount":"1250.90","currency":"BRL"}` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuctionEvent is a room event shared with every instance running the same
+// auction.
+type AuctionEvent struct {
+	Id        uuid.UUID `json:"id"`
+	Origin    uuid.UUID `json:"origin"`
+	ProductId uuid.UUID `json:"product_id"`
+	// SkipUserId is a user that was already told about the event directly,
+	// e.g. the bidder who placed the bid.
+	SkipUserId uuid.UUID `json:"skip_user_id"`
+	Message    Message   `json:"message"`
+}
+
+// maxQueuedEvents caps the events waiting for a room that does not keep up;
+// the oldest ones are dropped beyond it.
+const maxQueuedEvents = 1024
+
+// auctionSubscription queues the events of a room so a slow room never holds
+// up the listening loop, which is shared by every auction of the instance.
+type auctionSubscription struct {
+	events chan<- AuctionEvent
+	done   <-chan struct{}
+
+	mu      sync.Mutex
+	queue   []AuctionEvent
+	ready   chan struct{}
+	dropped int64
+}
+
+func newAuctionSubscription(events chan<- AuctionEvent, done <-chan struct{}) *auctionSubscription {
+	return &auctionSubscription{
+		events: events,
+		done:   done,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push queues an event without blocking.
+func (s *auctionSubscription) push(event AuctionEvent) {
+	s.mu.Lock()
+	if len(s.queue) == maxQueuedEvents {
+		s.queue = s.queue[1:]
+		s.dropped++
+		slog.Warn("Dropped auction event for a slow room", "auctionID", event.ProductId, "dropped", s.dropped)
+	}
+	s.queue = append(s.queue, event)
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// forward delivers the queued events to the room, in order, until done is
+// closed.
+func (s *auctionSubscription) forward() {
+	for {
+		select {
+		case <-s.ready:
+		case <-s.done:
+			return
+		}
+
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			event := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			select {
+			case s.events <- event:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// AuctionEventBus fans room events out to the other instances through
+// Postgres LISTEN/NOTIFY, using one channel per product.
+type AuctionEventBus struct {
+	pool       *pgxpool.Pool
+	instanceId uuid.UUID
+
+	mu            sync.Mutex
+	subscriptions map[uuid.UUID]*auctionSubscription
+	changed       chan struct{}
+}
+
+func NewAuctionEventBus(pool *pgxpool.Pool) *AuctionEventBus {
+	return &AuctionEventBus{
+		pool:          pool,
+		instanceId:    uuid.New(),
+		subscriptions: make(map[uuid.UUID]*auctionSubscription),
+		changed:       make(chan struct{}, 1),
+	}
+}
+
+const listenRetryDelay = 5 * time.Second
+
+func auctionChannel(productId uuid.UUID) string {
+	return "auction_" + strings.ReplaceAll(productId.String(), "-", "")
+}
+
+// Subscribe delivers the events other instances publish for productId to
+// events, until done is closed.
+func (b *AuctionEventBus) Subscribe(productId uuid.UUID, events chan<- AuctionEvent, done <-chan struct{}) {
+	subscription := newAuctionSubscription(events, done)
+	go subscription.forward()
+
+	b.mu.Lock()
+	b.subscriptions[productId] = subscription
+	b.mu.Unlock()
+
+	b.notifyChanged()
+}
+
+// Unsubscribe stops the deliveries to events. A newer subscription to the
+// same product is left alone.
+func (b *AuctionEventBus) Unsubscribe(productId uuid.UUID, events chan<- AuctionEvent) {
+	b.mu.Lock()
+	if subscription, ok := b.subscriptions[productId]; ok && subscription.events == events {
+		delete(b.subscriptions, productId)
+	}
+	b.mu.Unlock()
+
+	b.notifyChanged()
+}
+
+func (b *AuctionEventBus) notifyChanged() {
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Publish sends an event to the other instances. The event is stamped with
+// this instance as its origin so it is not delivered back here.
+func (b *AuctionEventBus) Publish(ctx context.Context, event AuctionEvent) error {
+	event.Origin = b.instanceId
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", auctionChannel(event.ProductId), string(payload))
+
+	return err
+}
+
+// Run listens for events published by other instances until ctx is done,
+// reconnecting whenever the listening connection is lost.
+func (b *AuctionEventBus) Run(ctx context.Context) {
+	for {
+		err := b.listen(ctx)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Error("Auction event listener stopped, reconnecting", "error", err)
+
+		select {
+		case <-time.After(listenRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *AuctionEventBus) listen(ctx context.Context) error {
+	conn, err := pgx.ConnectConfig(ctx, b.pool.Config().ConnConfig)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close(context.Background())
+
+	listening := make(map[string]bool)
+
+	for {
+		if err := b.syncChannels(ctx, conn, listening); err != nil {
+			return err
+		}
+
+		waitCtx, cancel := context.WithCancel(ctx)
+
+		go func() {
+			select {
+			case <-b.changed:
+				cancel()
+			case <-waitCtx.Done():
+			}
+		}()
+
+		notification, err := conn.WaitForNotification(waitCtx)
+		interrupted := waitCtx.Err() != nil
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if interrupted {
+				continue
+			}
+
+			return err
+		}
+
+		b.dispatch(notification)
+	}
+}
+
+// syncChannels makes the connection LISTEN to exactly the channels of the
+// currently subscribed products.
+func (b *AuctionEventBus) syncChannels(ctx context.Context, conn *pgx.Conn, listening map[string]bool) error {
+	wanted := make(map[string]bool)
+
+	b.mu.Lock()
+	for productId := range b.subscriptions {
+		wanted[auctionChannel(productId)] = true
+	}
+	b.mu.Unlock()
+
+	for channel := range wanted {
+		if listening[channel] {
+			continue
+		}
+
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+
+		listening[channel] = true
+	}
+
+	for channel := range listening {
+		if wanted[channel] {
+			continue
+		}
+
+		if _, err := conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+
+		delete(listening, channel)
+	}
+
+	return nil
+}
+
+func (b *AuctionEventBus) dispatch(notification *pgconn.Notification) {
+	var event AuctionEvent
+
+	if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+		slog.Error("Invalid auction event", "channel", notification.Channel, "error", err)
+		return
+	}
+
+	if event.Origin == b.instanceId {
+		return
+	}
+
+	b.mu.Lock()
+	subscription, ok := b.subscriptions[event.ProductId]
+	b.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	subscription.push(event)
+}
+
+const maxRecentEvents = 1024
+
+// recentEvents remembers the ids of the latest events of a room so each one
+// is only delivered once.
+type recentEvents struct {
+	ids   map[uuid.UUID]struct{}
+	order []uuid.UUID
+}
+
+// add records id and reports whether it was new.
+func (e *recentEvents) add(id uuid.UUID) bool {
+	if e.ids == nil {
+		e.ids = make(map[uuid.UUID]struct{})
+	}
+
+	if _, ok := e.ids[id]; ok {
+		return false
+	}
+
+	if len(e.order) == maxRecentEvents {
+		delete(e.ids, e.order[0])
+		e.order = e.order[1:]
+	}
+
+	e.ids[id] = struct{}{}
+	e.order = append(e.order, id)
+
+	return true
+}
+
+// maxReplayedEvents caps how many missed events a reconnecting client is
+// sent. Only the newest ones are replayed, right before the room snapshot;
+// older ones are only reflected in the snapshot.
+const maxReplayedEvents = 500
+
+// recordEvents stores room events in the transaction of the change they
+// describe, numbering them with the next sequence numbers of the auction.
+// The product row must be locked by the transaction.
+func recordEvents(ctx context.Context, queries *pgstore.Queries, productId uuid.UUID, messages []Message) ([]Message, error) {
+	for i := range messages {
+		payload, err := json.Marshal(messages[i])
+
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := queries.CreateAuctionEvent(ctx, pgstore.CreateAuctionEventParams{
+			ProductID: productId,
+			Payload:   payload,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		messages[i].Seq = event.Seq
+	}
+
+	return messages, nil
+}
+
+// EventsBetween returns the recorded events of an auction numbered after
+// afterSeq up to untilSeq, oldest first. When there are more than
+// maxReplayedEvents of them, only the newest are returned.
+func (bs *BidsService) EventsBetween(ctx context.Context, productId uuid.UUID, afterSeq, untilSeq int64) ([]Message, error) {
+	events, err := bs.queries.ListAuctionEventsBetween(ctx, pgstore.ListAuctionEventsBetweenParams{
+		ProductID: productId,
+		AfterSeq:  afterSeq,
+		UntilSeq:  untilSeq,
+		MaxEvents: maxReplayedEvents,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(events))
+
+	for _, event := range slices.Backward(events) {
+		var message Message
+
+		if err := json.Unmarshal(event.Payload, &message); err != nil {
+			return nil, err
+		}
+
+		message.Seq = event.Seq
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
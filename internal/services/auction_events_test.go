@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAuctionSubscriptionDoesNotBlock(t *testing.T) {
+	events := make(chan AuctionEvent)
+	done := make(chan struct{})
+	defer close(done)
+
+	subscription := newAuctionSubscription(events, done)
+	go subscription.forward()
+
+	pushed := make(chan struct{})
+
+	go func() {
+		for i := 0; i < maxQueuedEvents+10; i++ {
+			subscription.push(AuctionEvent{Id: uuid.New(), Message: Message{Seq: int64(i)}})
+		}
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push blocked on a room that is not reading its events")
+	}
+
+	var last int64 = -1
+	delivered := 0
+
+	for received := true; received; {
+		select {
+		case event := <-events:
+			if event.Message.Seq <= last {
+				t.Fatalf("event %d delivered after %d", event.Message.Seq, last)
+			}
+			last = event.Message.Seq
+			delivered++
+		case <-time.After(100 * time.Millisecond):
+			received = false
+		}
+	}
+
+	// forward may already hold the first event when the queue overflows.
+	if delivered < maxQueuedEvents || delivered > maxQueuedEvents+1 {
+		t.Errorf("delivered %d events, want the newest %d", delivered, maxQueuedEvents)
+	}
+
+	if last != maxQueuedEvents+9 {
+		t.Errorf("last event = %d, want %d", last, maxQueuedEvents+9)
+	}
+}
@@ -2,160 +2,903 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-type Messagekind int
+// Messagekind names an event of the websocket protocol. Its value is what
+// goes on the wire, so renaming a constant's value breaks clients.
+type Messagekind string
 
 const (
 	// Requests
-	PlaceBid Messagekind = iota
+	PlaceBid    Messagekind = "place_bid"
+	BuyNow      Messagekind = "buy_now"
+	AcceptPrice Messagekind = "accept_price"
 
 	// Success
-	SuccessfullyPlacedBid
+	SuccessfullyPlacedBid     Messagekind = "bid_placed"
+	SuccessfullyBoughtNow     Messagekind = "bought_now"
+	SuccessfullyAcceptedPrice Messagekind = "price_accepted"
 
 	// Errors
-	FailedToPlaceBid
-	InvalidJSON
+	FailedToPlaceBid    Messagekind = "bid_failed"
+	FailedToBuyNow      Messagekind = "buy_now_failed"
+	FailedToAcceptPrice Messagekind = "accept_price_failed"
+	InvalidJSON         Messagekind = "invalid_message"
 
 	// Info
-	NewBidPlaced
-	AuctionFinished
+	NewBidPlaced     Messagekind = "new_bid"
+	AuctionExtended  Messagekind = "auction_extended"
+	PriceDropped     Messagekind = "price_dropped"
+	AuctionFinished  Messagekind = "auction_finished"
+	RoomSnapshot     Messagekind = "room_snapshot"
+	TimeSync         Messagekind = "time_sync"
+	ClosingPhase     Messagekind = "closing_phase"
+	ServerRestarting Messagekind = "server_restarting"
+	AuctionUpdated   Messagekind = "auction_updated"
+	AuctionCancelled Messagekind = "auction_cancelled"
 )
 
+// endsAuction tells whether no more messages follow one of this kind.
+func (k Messagekind) endsAuction() bool {
+	return k == AuctionFinished || k == AuctionCancelled
+}
+
 type Message struct {
-	UserId  uuid.UUID   `json:"user_id,omitempty"`
-	Message string      `json:"message,omitempty"`
-	Amount  float64     `json:"amount,omitempty"`
-	Kind    Messagekind `json:"kind"`
+	UserId  uuid.UUID    `json:"user_id,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Amount  *money.Money `json:"amount,omitempty"`
+	// MaxAmount turns a PlaceBid request into a proxy bid. It is only ever
+	// echoed back to the bidder who set it.
+	MaxAmount  *money.Money `json:"max_amount,omitempty"`
+	AuctionEnd *time.Time   `json:"auction_end,omitempty"`
+	// ReserveMet tells bidders whether the hidden reserve price has been
+	// reached. It is omitted for products without a reserve.
+	ReserveMet     *bool            `json:"reserve_met,omitempty"`
+	MinimumNextBid *money.Money     `json:"minimum_next_bid,omitempty"`
+	Snapshot       *AuctionSnapshot `json:"snapshot,omitempty"`
+	// ServerTime is the room's clock when the message was sent, so clients
+	// can count down to AuctionEnd regardless of their own clock.
+	ServerTime *time.Time `json:"server_time,omitempty"`
+	// Phase names the closing phase a ClosingPhase message announces.
+	Phase string `json:"phase,omitempty"`
+	// ReconnectAfterMs is how long a client should wait before reconnecting
+	// after a ServerRestarting message.
+	ReconnectAfterMs int64 `json:"reconnect_after_ms,omitempty"`
+	// Seq numbers the events recorded for an auction. Clients pass the last
+	// one they saw when reconnecting to get the ones they missed.
+	Seq  int64       `json:"seq,omitempty"`
+	Kind Messagekind `json:"kind"`
+	// ConnectionId is the connection a request came from. Requests made
+	// without a connection, e.g. over REST, get their answer on Reply,
+	// which must be buffered.
+	ConnectionId uuid.UUID    `json:"-"`
+	Reply        chan Message `json:"-"`
 }
 
 type AuctionLobby struct {
 	sync.Mutex
 	Rooms map[uuid.UUID]*AuctionRoom
+	// ShuttingDown is set once CloseRooms has been called; rooms started
+	// after that must be shut down right away, see ShutdownRoom.
+	ShuttingDown bool
+	// closed holds every room shut down since, for Wait, as finished rooms
+	// leave Rooms.
+	closed []*AuctionRoom
 }
 
 type AuctionRoom struct {
-	Id         uuid.UUID
-	Context    context.Context
-	Broadcast  chan Message
-	Register   chan *Client
-	Unregister chan *Client
-	Clients    map[uuid.UUID]*Client
+	Id          uuid.UUID
+	SellerId    uuid.UUID
+	Context     context.Context
+	AuctionEnd  time.Time
+	AuctionType pgstore.AuctionType
+	Currency    string
+	Broadcast   chan Message
+	Register    chan *Client
+	Unregister  chan *Client
+	// Clients holds the connections of each bidder, by user and then by
+	// connection id, since a user may follow the auction from several
+	// devices or tabs at once.
+	Clients  map[uuid.UUID]map[uuid.UUID]*Client
+	Finished chan struct{}
+
+	// Spectators watch the auction without logging in. They are keyed by
+	// connection id and the room holds at most options.MaxSpectators of
+	// them.
+	Spectators map[uuid.UUID]*Client
+	options    RoomOptions
+
+	// timer fires at AuctionEnd; it is reset whenever a late bid extends it.
+	timer  *time.Timer
+	cancel context.CancelFunc
+
+	// phaseTimer fires when the closing phase at options.ClosingPhases[nextPhase]
+	// begins.
+	phaseTimer *time.Timer
+	nextPhase  int
+
+	// dutchSchedule is set for dutch auctions, whose price clock sends each
+	// new asking price on priceDrops.
+	dutchSchedule *DutchSchedule
+	priceDrops    chan money.Amount
+
+	// updates receives the new end of the auction when the seller edits it.
+	updates chan time.Time
+
+	// joins receives the snapshots read for the clients that just joined.
+	joins chan clientJoin
+
+	// events receives what the rooms of this auction on other instances
+	// publish through EventBus.
+	events            chan AuctionEvent
+	recentEvents      recentEvents
+	finishedElsewhere bool
+
+	counters roomCounters
 
-	BidsService BidsService
+	// shutdown is closed to make the room stop for a server restart;
+	// writers counts the websocket writers still running.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	restarting   atomic.Bool
+	writers      sync.WaitGroup
+
+	BidsService       BidsService
+	SettlementService SettlementService
+	EventBus          *AuctionEventBus
 }
 
 func (r *AuctionRoom) registerClient(client *Client) {
-	slog.Info("New user connected", "Client", client)
-	r.Clients[client.UserId] = client
+	if client.Conn != nil {
+		r.writers.Add(1)
+	}
+
+	if client.Spectator {
+		if len(r.Spectators) >= r.options.MaxSpectators {
+			r.closeClient(client, websocket.CloseTryAgainLater, "this auction has reached its spectator limit")
+			return
+		}
+
+		slog.Info("New spectator connected", "Client", client)
+		r.Spectators[client.Id] = client
+		r.counters.spectators.Add(1)
+	} else {
+		slog.Info("New user connected", "Client", client)
+
+		connections, ok := r.Clients[client.UserId]
+		if !ok {
+			connections = make(map[uuid.UUID]*Client)
+			r.Clients[client.UserId] = connections
+		}
+		connections[client.Id] = client
+	}
+
+	client.joining = true
+	go r.readJoin(client)
+}
+
+// forEachClient calls fn for every bidder and spectator in the room.
+func (r *AuctionRoom) forEachClient(fn func(client *Client)) {
+	for _, connections := range r.Clients {
+		for _, client := range connections {
+			fn(client)
+		}
+	}
+
+	for _, client := range r.Spectators {
+		fn(client)
+	}
+}
+
+// connection finds a single connection of a bidder or spectator.
+func (r *AuctionRoom) connection(userId, connectionId uuid.UUID) (*Client, bool) {
+	if client, ok := r.Clients[userId][connectionId]; ok {
+		return client, true
+	}
+
+	client, ok := r.Spectators[connectionId]
+	return client, ok
+}
+
+// removeClient takes a single connection out of the room. It is a no-op if
+// the room has already removed it.
+func (r *AuctionRoom) removeClient(client *Client) {
+	if client.Spectator {
+		if _, ok := r.Spectators[client.Id]; ok {
+			delete(r.Spectators, client.Id)
+			r.counters.spectators.Add(-1)
+		}
+		return
+	}
+
+	connections := r.Clients[client.UserId]
+	delete(connections, client.Id)
+
+	if len(connections) == 0 {
+		delete(r.Clients, client.UserId)
+	}
+}
+
+// clientJoin is the state a client that just joined is brought up to date
+// with.
+type clientJoin struct {
+	client   *Client
+	snapshot AuctionSnapshot
+	missed   []Message
+	err      error
+}
+
+// readJoin reads the snapshot of a client that just joined, and the events
+// it missed if it is reconnecting, off the room goroutine. The snapshot is
+// read from the database, which is the source of truth when several
+// instances run the same auction.
+func (r *AuctionRoom) readJoin(client *Client) {
+	join := clientJoin{client: client}
+	join.snapshot, join.err = r.BidsService.AuctionSnapshot(r.Context, r.Id, client.UserId)
+
+	if join.err == nil && client.since != nil {
+		missed, err := r.BidsService.EventsBetween(r.Context, r.Id, *client.since, join.snapshot.LastSeq)
+
+		if err != nil {
+			slog.Error("Failed to replay auction events", "auctionID", r.Id, "error", err)
+		}
+
+		join.missed = missed
+	}
+
+	select {
+	case r.joins <- join:
+	case <-r.Finished:
+	}
+}
+
+// completeJoin sends a client the events it missed and its snapshot, then
+// what the room held back for it while they were read. Held events the
+// snapshot already covers are skipped by their seq, so its bid stream has
+// no gaps nor repeats.
+func (r *AuctionRoom) completeJoin(join clientJoin) {
+	client := join.client
+
+	if current, ok := r.connection(client.UserId, client.Id); !ok || current != client || client.closed {
+		return
+	}
+
+	client.joining = false
+
+	if join.err != nil {
+		slog.Error("Failed to read auction snapshot", "auctionID", r.Id, "error", join.err)
+	} else {
+		for _, message := range join.missed {
+			r.send(client, message)
+		}
+
+		client.lastSeq = join.snapshot.LastSeq
+
+		r.send(client, Message{
+			Kind:     RoomSnapshot,
+			Message:  "Current state of the auction",
+			Snapshot: &join.snapshot,
+			Seq:      join.snapshot.LastSeq,
+		})
+	}
+
+	r.releaseHeld(client)
+}
+
+// releaseHeld sends a client what the room held back for it while it was
+// joining.
+func (r *AuctionRoom) releaseHeld(client *Client) {
+	client.joining = false
+	held := client.held
+	client.held = nil
+
+	for _, message := range held {
+		if message.Seq != 0 {
+			if message.Seq <= client.lastSeq {
+				continue
+			}
+			client.lastSeq = message.Seq
+		}
+
+		r.send(client, message)
+	}
 }
 
 func (r *AuctionRoom) unregisterClient(client *Client) {
 	slog.Info("User disconnected", "Client", client)
 
-	delete(r.Clients, client.UserId)
+	r.removeClient(client)
+}
+
+// sendToUser sends a message to every connection of a user. A spectator
+// only has the one connection its random user id stands for.
+func (r *AuctionRoom) sendToUser(userId uuid.UUID, message Message) {
+	for _, client := range r.Clients[userId] {
+		r.send(client, message)
+	}
+
+	if client, ok := r.Spectators[userId]; ok {
+		r.send(client, message)
+	}
+}
+
+var ErrSpectatorCannotBid = errors.New("spectators cannot bid, log in to take part in the auction")
+
+// reply answers a request on every connection of its sender, and on its
+// Reply channel when it came from outside a connection.
+func (r *AuctionRoom) reply(request Message, response Message) {
+	r.sendToUser(request.UserId, response)
+
+	if request.Reply != nil {
+		select {
+		case request.Reply <- response:
+		default:
+		}
+	}
+}
+
+// failureKind is the kind of the message rejecting a request.
+func failureKind(request Messagekind) (Messagekind, bool) {
+	switch request {
+	case PlaceBid:
+		return FailedToPlaceBid, true
+	case BuyNow:
+		return FailedToBuyNow, true
+	case AcceptPrice:
+		return FailedToAcceptPrice, true
+	default:
+		return "", false
+	}
+}
+
+// bidFailureReason is the message shown to a bidder whose request was
+// rejected. Unexpected errors are logged and not exposed.
+func (r *AuctionRoom) bidFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrBidIsTooLow),
+		errors.Is(err, ErrBidBelowIncrement),
+		errors.Is(err, ErrAuctionEnded),
+		errors.Is(err, ErrAuctionCancelled),
+		errors.Is(err, ErrBidderIsSeller),
+		errors.Is(err, ErrCurrencyMismatch),
+		errors.Is(err, ErrBuyNowUnavailable),
+		errors.Is(err, ErrUnsupportedForAuctionType),
+		errors.Is(err, ErrProductNotFound):
+		return err.Error()
+	default:
+		slog.Error("Failed to place bid", "auctionID", r.Id, "error", err)
+		return "could not place your bid, try again later"
+	}
 }
 
 func (r *AuctionRoom) broadcastMessage(message Message) {
 	slog.Info("New message received", "RoomID", r.Id, "Message", message, "Userid", message.UserId)
 
+	if _, ok := r.Spectators[message.UserId]; ok {
+		if kind, ok := failureKind(message.Kind); ok {
+			r.reply(message, Message{
+				Kind:    kind,
+				Message: ErrSpectatorCannotBid.Error(),
+				UserId:  message.UserId,
+			})
+			return
+		}
+	}
+
 	switch message.Kind {
 	case PlaceBid:
-		bid, err := r.BidsService.PlaceBid(r.Context, r.Id, message.UserId, message.Amount)
+		if message.Amount == nil && message.MaxAmount == nil {
+			r.reply(message, Message{
+				Kind:    FailedToPlaceBid,
+				Message: "amount or max_amount is required to place a bid",
+				UserId:  message.UserId,
+			})
+			return
+		}
+
+		var placed PlacedBids
+		var err error
+
+		if message.MaxAmount != nil {
+			placed, err = r.BidsService.PlaceProxyBid(r.Context, r.Id, message.UserId, *message.MaxAmount)
+		} else {
+			placed, err = r.BidsService.PlaceBid(r.Context, r.Id, message.UserId, *message.Amount)
+		}
 
 		if err != nil {
-			if errors.Is(err, ErrBidIsTooLow) {
-				if client, ok := r.Clients[message.UserId]; ok {
-					client.Send <- Message{
-						Kind:    FailedToPlaceBid,
-						Message: ErrBidIsTooLow.Error(),
-						UserId:  message.UserId,
-					}
-				}
+			failedMessage := Message{
+				Kind:    FailedToPlaceBid,
+				Message: r.bidFailureReason(err),
+				UserId:  message.UserId,
 			}
+
+			var belowIncrement *BidBelowIncrementError
+			if errors.As(err, &belowIncrement) {
+				failedMessage.MinimumNextBid = &belowIncrement.MinimumNextBid
+			}
+
+			r.reply(message, failedMessage)
 			return
 		}
 
-		if client, ok := r.Clients[message.UserId]; ok {
-			client.Send <- Message{
+		if isSealed(r.AuctionType) {
+			// Sealed bids are acknowledged to their bidder and never broadcast.
+			bidAmount := money.New(placed.Bids[0].BidAmount, placed.Currency)
+
+			r.reply(message, Message{
 				Kind:    SuccessfullyPlacedBid,
-				Message: "Your Bid was placed successfully",
+				Message: "Your sealed bid was recorded",
+				Amount:  &bidAmount,
 				UserId:  message.UserId,
+			})
+			return
+		}
+
+		successMessage := Message{
+			Kind:           SuccessfullyPlacedBid,
+			Message:        "Your Bid was placed successfully",
+			UserId:         message.UserId,
+			ReserveMet:     placed.ReserveMet,
+			MinimumNextBid: &placed.MinimumNextBid,
+		}
+
+		if message.MaxAmount != nil {
+			successMessage.Message = "Your maximum bid was registered"
+			successMessage.MaxAmount = message.MaxAmount
+		}
+
+		r.reply(message, successMessage)
+
+		if placed.Extended {
+			r.moveAuctionEnd(placed.AuctionEnd)
+		}
+
+		for i, eventMessage := range placed.Events {
+			event := AuctionEvent{Message: eventMessage}
+
+			if i == 0 && message.MaxAmount == nil {
+				event.SkipUserId = message.UserId
 			}
+
+			r.publish(event)
 		}
+	case BuyNow:
+		placed, err := r.BidsService.BuyNow(r.Context, r.Id, message.UserId)
 
-		for id, client := range r.Clients {
-			newBidMessage := Message{
-				Message: "A new bid was placed",
-				Amount:  bid.BidAmount,
-				Kind:    NewBidPlaced,
+		if err != nil {
+			r.reply(message, Message{
+				Kind:    FailedToBuyNow,
+				Message: r.bidFailureReason(err),
 				UserId:  message.UserId,
-			}
+			})
+			return
+		}
 
-			if id != message.UserId {
-				client.Send <- newBidMessage
-			}
+		price := money.New(placed.Bids[0].BidAmount, placed.Currency)
+
+		r.reply(message, Message{
+			Kind:    SuccessfullyBoughtNow,
+			Message: "You bought this product",
+			Amount:  &price,
+			UserId:  message.UserId,
+		})
+
+		// The buyer was told above; everyone else hears about the bid.
+		for _, eventMessage := range placed.Events {
+			r.publish(AuctionEvent{Message: eventMessage, SkipUserId: message.UserId})
 		}
+
+		// The auction is over; Run settles it once the context is done.
+		r.cancel()
+	case AcceptPrice:
+		placed, err := r.BidsService.AcceptPrice(r.Context, r.Id, message.UserId)
+
+		if err != nil {
+			r.reply(message, Message{
+				Kind:    FailedToAcceptPrice,
+				Message: r.bidFailureReason(err),
+				UserId:  message.UserId,
+			})
+			return
+		}
+
+		price := money.New(placed.Bids[0].BidAmount, placed.Currency)
+
+		r.reply(message, Message{
+			Kind:    SuccessfullyAcceptedPrice,
+			Message: "You bought this product",
+			Amount:  &price,
+			UserId:  message.UserId,
+		})
+
+		r.cancel()
 	case InvalidJSON:
-		client, ok := r.Clients[message.UserId]
+		// Only the connection that sent the invalid message is told.
+		client, ok := r.connection(message.UserId, message.ConnectionId)
 		if !ok {
 			slog.Info("Client not found", "UserID", message.UserId)
 			return
 		}
 
-		client.Send <- message
+		r.send(client, message)
+	}
+
+}
+
+func (r *AuctionRoom) moveAuctionEnd(auctionEnd time.Time) {
+	if !auctionEnd.After(r.AuctionEnd) {
+		return
+	}
+
+	slog.Info("Auction has been extended", "auctionID", r.Id, "auctionEnd", auctionEnd)
+
+	r.setAuctionEnd(auctionEnd)
+}
+
+// setAuctionEnd moves the end of the auction, earlier or later.
+func (r *AuctionRoom) setAuctionEnd(auctionEnd time.Time) {
+	r.AuctionEnd = auctionEnd
+	r.timer.Reset(time.Until(auctionEnd))
+	r.scheduleClosingPhase()
+}
+
+// Update tells the room that the seller edited the auction, which may have
+// moved its end. Clients are told to fetch the product again.
+func (r *AuctionRoom) Update(auctionEnd time.Time) {
+	select {
+	case r.updates <- auctionEnd:
+	case <-r.Finished:
+	}
+}
+
+func (r *AuctionRoom) applyUpdate(auctionEnd time.Time) {
+	slog.Info("Auction has been updated", "auctionID", r.Id, "auctionEnd", auctionEnd)
+
+	r.setAuctionEnd(auctionEnd)
+	r.publish(AuctionEvent{
+		Message: Message{
+			Kind:       AuctionUpdated,
+			Message:    "The seller has updated the auction",
+			AuctionEnd: &auctionEnd,
+		},
+	})
+}
+
+// Cancel closes the room of an auction its seller cancelled. Its clients,
+// and the rooms of the auction on other instances, are told so.
+func (r *AuctionRoom) Cancel() {
+	r.cancel()
+}
+
+// publish delivers an event to the clients of this room and to the rooms of
+// the same auction on the other instances.
+func (r *AuctionRoom) publish(event AuctionEvent) {
+	event.Id = uuid.New()
+	event.ProductId = r.Id
+
+	r.recentEvents.add(event.Id)
+	r.deliverEvent(event)
+	r.publishRemote(event)
+}
+
+func (r *AuctionRoom) publishRemote(event AuctionEvent) {
+	if r.EventBus == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := r.EventBus.Publish(ctx, event); err != nil {
+		slog.Error("Failed to publish auction event", "auctionID", r.Id, "error", err)
+	}
+}
+
+func (r *AuctionRoom) deliverEvent(event AuctionEvent) {
+	seq := event.Message.Seq
+
+	r.forEachClient(func(client *Client) {
+		// Numbered events a client already got through its snapshot or
+		// replay are not sent again.
+		// Clients still joining get them held back, see completeJoin.
+		if seq != 0 && !client.joining {
+			if seq <= client.lastSeq {
+				return
+			}
+			client.lastSeq = seq
+		}
+
+		if client.UserId == event.SkipUserId {
+			return
+		}
+
+		r.send(client, event.Message)
+	})
+}
+
+// receiveEvent applies an event published by another instance, once.
+func (r *AuctionRoom) receiveEvent(event AuctionEvent) {
+	if !r.recentEvents.add(event.Id) {
+		return
+	}
+
+	switch event.Message.Kind {
+	case AuctionExtended:
+		if event.Message.AuctionEnd != nil {
+			r.moveAuctionEnd(*event.Message.AuctionEnd)
+		}
+	case AuctionUpdated:
+		if event.Message.AuctionEnd != nil {
+			r.setAuctionEnd(*event.Message.AuctionEnd)
+		}
+	case AuctionFinished, AuctionCancelled:
+		// Settling is idempotent, so this room settles on its own and
+		// reports the same outcome to its clients. A cancelled auction
+		// cannot be settled, which tells the room to report the
+		// cancellation instead.
+		r.finishedElsewhere = true
+		r.cancel()
+		return
 	}
 
+	r.deliverEvent(event)
+}
+
+func (r *AuctionRoom) broadcastPriceDrop(price money.Amount) {
+	askingPrice := money.New(price, r.Currency)
+
+	r.forEachClient(func(client *Client) {
+		r.send(client, Message{
+			Kind:    PriceDropped,
+			Message: "The asking price has dropped",
+			Amount:  &askingPrice,
+		})
+	})
+}
+
+// runPriceClock sends the asking price of a dutch auction to the room every
+// time it drops, until it reaches the floor or the room finishes.
+func (r *AuctionRoom) runPriceClock(schedule DutchSchedule) {
+	for {
+		dropAt, ok := schedule.NextDropAt(time.Now())
+
+		if !ok {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(dropAt))
+
+		select {
+		case <-timer.C:
+			select {
+			case r.priceDrops <- schedule.PriceAt(dropAt):
+			case <-r.Finished:
+				return
+			}
+		case <-r.Finished:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// finishAuction settles the auction and tells every client about the
+// outcome. If the auction has not actually ended the room is closed, unless
+// it is still running because another instance extended it: then the timer
+// is moved to the new end and finishAuction returns false. Clients of a
+// cancelled auction are told it was cancelled.
+func (r *AuctionRoom) finishAuction() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), settlementTimeout)
+	defer cancel()
+
+	result, err := r.SettlementService.SettleAuction(ctx, r.Id)
+
+	var notEnded *AuctionNotEndedError
+	if errors.As(err, &notEnded) && r.Context.Err() == nil {
+		r.moveAuctionEnd(notEnded.AuctionEnd)
+		return false
+	}
+
+	if errors.Is(err, ErrAuctionNotEnded) {
+		slog.Info("Auction room has been closed", "auctionID", r.Id)
+		return true
+	}
+
+	if errors.Is(err, ErrAuctionCancelled) {
+		r.closeCancelled()
+		return true
+	}
+
+	slog.Info("Auction has ended", "auctionID", r.Id)
+
+	auctionEnd, now := r.AuctionEnd, time.Now()
+	finishedMessage := Message{
+		Kind:       AuctionFinished,
+		Message:    "Auction has been finished",
+		AuctionEnd: &auctionEnd,
+		ServerTime: &now,
+	}
+
+	switch {
+	case err != nil:
+		slog.Error("Failed to settle auction", "auctionID", r.Id, "error", err)
+	case !result.IsSold:
+		finishedMessage.Message = "Auction has been finished without a winner"
+	default:
+		hammerPrice := money.New(*result.HammerPrice, result.Currency)
+		finishedMessage.UserId = result.WinnerID.UUID
+		finishedMessage.Amount = &hammerPrice
+	}
+
+	r.forEachClient(func(client *Client) {
+		r.send(client, finishedMessage)
+	})
+
+	if !r.finishedElsewhere {
+		r.publishRemote(AuctionEvent{
+			Id:        uuid.New(),
+			ProductId: r.Id,
+			Message:   finishedMessage,
+		})
+	}
+
+	return true
+}
+
+func (r *AuctionRoom) closeCancelled() {
+	slog.Info("Auction has been cancelled", "auctionID", r.Id)
+
+	cancelledMessage := Message{
+		Kind:    AuctionCancelled,
+		Message: "The seller has cancelled the auction",
+	}
+
+	r.forEachClient(func(client *Client) {
+		r.send(client, cancelledMessage)
+	})
+
+	if !r.finishedElsewhere {
+		r.publishRemote(AuctionEvent{
+			Id:        uuid.New(),
+			ProductId: r.Id,
+			Message:   cancelledMessage,
+		})
+	}
 }
 
 func (r *AuctionRoom) Run() {
 	slog.Info("Auction has started", "auctionID", r.Id)
 
+	r.timer = time.NewTimer(time.Until(r.AuctionEnd))
+	r.phaseTimer = time.NewTimer(0)
+	r.phaseTimer.Stop()
+	r.scheduleClosingPhase()
+
+	if r.dutchSchedule != nil {
+		go r.runPriceClock(*r.dutchSchedule)
+	}
+
+	if r.EventBus != nil {
+		r.EventBus.Subscribe(r.Id, r.events, r.Finished)
+	}
+
+	slowClients := time.NewTicker(slowClientCheck)
+
+	var timeSync <-chan time.Time
+	if r.options.TimeSyncInterval > 0 {
+		ticker := time.NewTicker(r.options.TimeSyncInterval)
+		defer ticker.Stop()
+		timeSync = ticker.C
+	}
+
 	defer func() {
-		close(r.Broadcast)
-		close(r.Register)
-		close(r.Unregister)
+		r.timer.Stop()
+		r.phaseTimer.Stop()
+		slowClients.Stop()
+
+		if r.EventBus != nil {
+			r.EventBus.Unsubscribe(r.Id, r.events)
+		}
+
+		close(r.Finished)
 	}()
 
 	for {
 		select {
 		case client := <-r.Register:
 			r.registerClient(client)
+		case join := <-r.joins:
+			r.completeJoin(join)
 		case client := <-r.Unregister:
 			r.unregisterClient(client)
 		case message := <-r.Broadcast:
 			r.broadcastMessage(message)
-		case <-r.Context.Done():
-			slog.Info("Auction has ended", "auctionID", r.Id)
-
-			for _, client := range r.Clients {
-				client.Send <- Message{
-					Kind:    AuctionFinished,
-					Message: "Auction has been finished",
-				}
+		case price := <-r.priceDrops:
+			r.broadcastPriceDrop(price)
+		case event := <-r.events:
+			r.receiveEvent(event)
+		case auctionEnd := <-r.updates:
+			r.applyUpdate(auctionEnd)
+		case <-slowClients.C:
+			r.checkSlowClients()
+		case <-timeSync:
+			r.syncTime()
+		case <-r.phaseTimer.C:
+			r.announceClosingPhase()
+		case <-r.timer.C:
+			if r.finishAuction() {
+				return
 			}
-
+		case <-r.shutdown:
+			r.closeForRestart()
+			return
+		case <-r.Context.Done():
+			r.finishAuction()
 			return
 		}
 	}
 }
 
-func NewAuctionRoom(ctx context.Context, id uuid.UUID, bidService BidsService) *AuctionRoom {
+const (
+	settlementTimeout = 30 * time.Second
+	publishTimeout    = 5 * time.Second
+)
+
+// RoomOptions configures the auction rooms of an instance.
+type RoomOptions struct {
+	// MaxSpectators caps the anonymous viewers of each room.
+	MaxSpectators int
+	// TimeSyncInterval is how often a room sends its clock to its clients;
+	// zero turns it off.
+	TimeSyncInterval time.Duration
+	// ClosingPhases are the times left at which a room announces that the
+	// auction is about to close, e.g. 5m, 1m and 10s.
+	ClosingPhases []time.Duration
+}
+
+func NewAuctionRoom(ctx context.Context, product pgstore.Product, bidService BidsService, settlementService SettlementService, eventBus *AuctionEventBus, options RoomOptions) *AuctionRoom {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var dutchSchedule *DutchSchedule
+	if schedule, ok := NewDutchSchedule(product); ok {
+		dutchSchedule = &schedule
+	}
+
 	return &AuctionRoom{
-		Id:          id,
-		Broadcast:   make(chan Message),
-		Register:    make(chan *Client),
-		Unregister:  make(chan *Client),
-		Clients:     make(map[uuid.UUID]*Client),
-		Context:     ctx,
-		BidsService: bidService,
+		Id:                product.ID,
+		SellerId:          product.SellerID,
+		Broadcast:         make(chan Message),
+		Register:          make(chan *Client),
+		Unregister:        make(chan *Client),
+		Clients:           make(map[uuid.UUID]map[uuid.UUID]*Client),
+		Spectators:        make(map[uuid.UUID]*Client),
+		options:           options.sorted(),
+		Finished:          make(chan struct{}),
+		Context:           ctx,
+		AuctionEnd:        product.AuctionEnd,
+		AuctionType:       product.AuctionType,
+		Currency:          product.Currency,
+		dutchSchedule:     dutchSchedule,
+		priceDrops:        make(chan money.Amount),
+		events:            make(chan AuctionEvent, 64),
+		updates:           make(chan time.Time),
+		joins:             make(chan clientJoin),
+		shutdown:          make(chan struct{}),
+		cancel:            cancel,
+		BidsService:       bidService,
+		SettlementService: settlementService,
+		EventBus:          eventBus,
 	}
 }
 
@@ -164,17 +907,73 @@ type Client struct {
 	Conn   *websocket.Conn
 	Send   chan Message
 	UserId uuid.UUID
+	// Id identifies the connection; a user may have several.
+	Id uuid.UUID
+	// Spectator clients are not logged in; their UserId is their connection
+	// id.
+	Spectator bool
+
+	// protocol is the websocket subprotocol negotiated by the client.
+	protocol string
+
+	// pending holds coalescible messages that did not fit in Send while the
+	// client is behind, since laggingSince. When the room gives up on the
+	// client it closes Send after setting closeCode and closeReason. These
+	// fields are only used by the room goroutine until Send is closed.
+	pending      []Message
+	laggingSince time.Time
+	closed       bool
+	closeCode    int
+	closeReason  string
+
+	// since is the last event the client saw before reconnecting, if any.
+	// lastSeq is the last event it has been sent; it is only used by the
+	// room goroutine.
+	since   *int64
+	lastSeq int64
+
+	// joining is set until the client got its snapshot; meanwhile the room
+	// holds back what it sends the client in held.
+	joining bool
+	held    []Message
+}
+
+// NewSpectator is a client watching the auction without being logged in.
+func NewSpectator(room *AuctionRoom, conn *websocket.Conn, since *int64) *Client {
+	client := NewClient(room, conn, uuid.Nil, since)
+	client.UserId = client.Id
+	client.Spectator = true
+	return client
 }
 
-func NewClient(room *AuctionRoom, conn *websocket.Conn, userId uuid.UUID) *Client {
+// NewClient registers a connection of userId. conn is nil for clients that
+// read Send themselves, such as event streams, which always use the
+// versioned protocol.
+func NewClient(room *AuctionRoom, conn *websocket.Conn, userId uuid.UUID, since *int64) *Client {
+	protocol := ProtocolV2
+	if conn != nil {
+		protocol = conn.Subprotocol()
+	}
+
 	return &Client{
-		Room:   room,
-		Conn:   conn,
-		Send:   make(chan Message, 512),
-		UserId: userId,
+		Room:     room,
+		Conn:     conn,
+		Send:     make(chan Message, 512),
+		UserId:   userId,
+		Id:       uuid.New(),
+		protocol: protocol,
+		since:    since,
+
+		closeCode:   websocket.CloseNormalClosure,
+		closeReason: "connection closed",
 	}
 }
 
+// CloseReason tells why the room closed Send.
+func (c *Client) CloseReason() string {
+	return c.closeReason
+}
+
 const (
 	maxMassageSize = 512
 	readDeadline   = 60 * time.Second
@@ -182,9 +981,27 @@ const (
 	pingPeriod     = (readDeadline * 9) / 10
 )
 
+// sendToRoom delivers a message to the room unless the room has already
+// finished, in which case nobody is left to receive it.
+func (c *Client) sendToRoom(message Message) bool {
+	select {
+	case c.Room.Broadcast <- message:
+		return true
+	case <-c.Room.Finished:
+		return false
+	}
+}
+
+func (c *Client) Unregister() {
+	select {
+	case c.Room.Unregister <- c:
+	case <-c.Room.Finished:
+	}
+}
+
 func (c *Client) ReadEventLoop() {
 	defer func() {
-		c.Room.Unregister <- c
+		c.Unregister()
 		c.Conn.Close()
 	}()
 
@@ -196,58 +1013,103 @@ func (c *Client) ReadEventLoop() {
 	})
 
 	for {
-		var message Message
-		message.UserId = c.UserId
-
-		err := c.Conn.ReadJSON(&message)
+		_, data, err := c.Conn.ReadMessage()
 
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				slog.Error("Unexpected Close error: %v", "error", err)
-				return
 			}
+			return
+		}
 
-			c.Room.Broadcast <- Message{
-				Kind:    InvalidJSON,
-				Message: "this message should be a valid JSON",
-				UserId:  message.UserId,
+		message, err := c.decode(data)
+
+		if err != nil {
+			reason := "this message should be a valid JSON"
+			if errors.Is(err, ErrUnsupportedMessageType) {
+				reason = err.Error()
+			}
+
+			if !c.sendToRoom(Message{
+				Kind:         InvalidJSON,
+				Message:      reason,
+				UserId:       c.UserId,
+				ConnectionId: c.Id,
+			}) {
+				return
 			}
 			continue
 		}
-		c.Room.Broadcast <- message
+
+		// The sender is always the authenticated user, whatever the message
+		// says.
+		message.UserId = c.UserId
+		message.ConnectionId = c.Id
+
+		if !c.sendToRoom(message) {
+			return
+		}
 	}
 }
 
+// decode reads a request in the protocol negotiated by the client.
+func (c *Client) decode(data []byte) (Message, error) {
+	if c.protocol == ProtocolV2 {
+		return decodeRequest(data)
+	}
+
+	var message Message
+
+	if err := json.Unmarshal(data, &message); err != nil {
+		return Message{}, err
+	}
+
+	// Legacy clients may leave out the kind of a bid, whose numeric value
+	// is zero.
+	if message.Kind == "" {
+		message.Kind = PlaceBid
+	}
+
+	return message, nil
+}
+
+// Encode writes a message in the protocol negotiated by the client.
+func (c *Client) Encode(message Message) any {
+	if c.protocol == ProtocolV2 {
+		return encodeEnvelope(message, c.Room.Id, c.UserId)
+	}
+
+	return encodeLegacy(message, c.Room.Id, c.UserId, c.Spectator)
+}
+
 func (c *Client) WriteEventLoop() {
 	ticker := time.NewTicker(pingPeriod)
 
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
+		c.Room.writers.Done()
 	}()
 
 	for {
 		select {
 		case message, ok := <-c.Send:
 			if !ok {
-				c.Conn.WriteJSON(Message{
-					Kind:    websocket.CloseMessage,
-					Message: "Connection closed",
-				})
-				return
-			}
-
-			if message.Kind == AuctionFinished {
-				close(c.Send)
+				c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
 				return
 			}
 
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 
-			err := c.Conn.WriteJSON(message)
+			err := c.Conn.WriteJSON(c.Encode(message))
 
 			if err != nil {
-				c.Room.Unregister <- c
+				c.Unregister()
+				return
+			}
+
+			if message.Kind.endsAuction() {
 				return
 			}
 
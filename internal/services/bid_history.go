@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Where a bidder stands in an auction they bid on.
+const (
+	BidderStatusWinning = "winning"
+	BidderStatusOutbid  = "outbid"
+	BidderStatusWon     = "won"
+	BidderStatusLost    = "lost"
+	// BidderStatusSealed is shown while a sealed auction runs, since no one
+	// may know who leads it.
+	BidderStatusSealed    = "sealed"
+	BidderStatusCancelled = "cancelled"
+)
+
+var ErrBidsHidden = errors.New("bids of a sealed auction stay hidden until it ends, and for good if it is cancelled")
+
+// BidHistoryEntry is a bid as everyone sees it. The bidder is only
+// identified by their alias in the auction, see BidderAlias.
+type BidHistoryEntry struct {
+	Id        uuid.UUID   `json:"id"`
+	Bidder    string      `json:"bidder"`
+	Amount    money.Money `json:"amount"`
+	CreatedAt time.Time   `json:"created_at"`
+	// IsYours tells the logged in viewer which bids are theirs.
+	IsYours bool `json:"is_yours"`
+}
+
+type BidHistoryPage struct {
+	Bids []BidHistoryEntry `json:"bids"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// BidderAuction is an auction as seen by one of its bidders.
+type BidderAuction struct {
+	ProductId   uuid.UUID           `json:"product_id"`
+	ProductName string              `json:"product_name"`
+	AuctionType pgstore.AuctionType `json:"auction_type"`
+	HighestBid  money.Money         `json:"highest_bid"`
+	BidCount    int64               `json:"bid_count"`
+	LastBidAt   time.Time           `json:"last_bid_at"`
+	Status      string              `json:"status"`
+	AuctionEnd  time.Time           `json:"auction_end"`
+}
+
+// bidCursor is the last bid of a page of a bid history.
+type bidCursor struct {
+	CreatedAt time.Time `json:"t"`
+	Id        uuid.UUID `json:"i"`
+}
+
+func (c bidCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBidCursor(raw string) (bidCursor, error) {
+	var cursor bidCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+
+	if err != nil {
+		return bidCursor{}, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return bidCursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// BidHistory lists the bids of an auction newest first, limit at a time,
+// starting after cursor unless it is empty. The bids of a sealed auction
+// are only shown once it ends, see bidsHidden.
+func (bs *BidsService) BidHistory(ctx context.Context, product_id, viewer_id uuid.UUID, cursor string, limit int32) (BidHistoryPage, error) {
+	product, err := bs.queries.GetProductById(ctx, product_id)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return BidHistoryPage{}, ErrProductNotFound
+		}
+		return BidHistoryPage{}, err
+	}
+
+	if bidsHidden(product.AuctionType, product.CancelledAt.Valid, product.AuctionEnd, time.Now()) {
+		return BidHistoryPage{}, ErrBidsHidden
+	}
+
+	params := pgstore.ListBidsByProductIdParams{
+		ProductID: product_id,
+		// One more than asked for tells whether there is a next page.
+		MaxBids: limit + 1,
+	}
+
+	if cursor != "" {
+		after, err := decodeBidCursor(cursor)
+
+		if err != nil {
+			return BidHistoryPage{}, err
+		}
+
+		params.BeforeCreatedAt = pgtype.Timestamp{Time: after.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: after.Id, Valid: true}
+	}
+
+	bids, err := bs.queries.ListBidsByProductId(ctx, params)
+
+	if err != nil {
+		return BidHistoryPage{}, err
+	}
+
+	page := BidHistoryPage{Bids: make([]BidHistoryEntry, 0, len(bids))}
+
+	if len(bids) > int(limit) {
+		bids = bids[:limit]
+		last := bids[len(bids)-1]
+		page.NextCursor = bidCursor{CreatedAt: last.CreatedAt.Time, Id: last.ID}.encode()
+	}
+
+	for _, bid := range bids {
+		page.Bids = append(page.Bids, BidHistoryEntry{
+			Id:        bid.ID,
+			Bidder:    BidderAlias(product_id, bid.BidderID),
+			Amount:    money.New(bid.BidAmount, product.Currency),
+			CreatedAt: bid.CreatedAt.Time,
+			IsYours:   viewer_id != uuid.Nil && bid.BidderID == viewer_id,
+		})
+	}
+
+	return page, nil
+}
+
+// BidderAuctions lists every auction bidder_id bid on, latest ending first.
+func (bs *BidsService) BidderAuctions(ctx context.Context, bidder_id uuid.UUID) ([]BidderAuction, error) {
+	rows, err := bs.queries.ListAuctionsByBidderId(ctx, bidder_id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	auctions := make([]BidderAuction, 0, len(rows))
+
+	for _, row := range rows {
+		auctions = append(auctions, BidderAuction{
+			ProductId:   row.ID,
+			ProductName: row.ProductName,
+			AuctionType: row.AuctionType,
+			HighestBid:  money.New(money.Amount(row.HighestBid), row.Currency),
+			BidCount:    row.BidCount,
+			LastBidAt:   row.LastBidAt.Time,
+			Status:      bidderStatus(row, bidder_id, now),
+			AuctionEnd:  row.AuctionEnd,
+		})
+	}
+
+	return auctions, nil
+}
+
+// bidderStatus tells where bidder_id stands in an auction. Until an ended
+// auction is settled, which also checks its reserve price, its leader is
+// still winning.
+func bidderStatus(row pgstore.ListAuctionsByBidderIdRow, bidder_id uuid.UUID, now time.Time) string {
+	switch {
+	case row.CancelledAt.Valid:
+		return BidderStatusCancelled
+	case row.Settled && row.WinnerID.Valid && row.WinnerID.UUID == bidder_id:
+		return BidderStatusWon
+	case row.Settled:
+		return BidderStatusLost
+	case bidsHidden(row.AuctionType, false, row.AuctionEnd, now):
+		return BidderStatusSealed
+	case row.LeaderID == bidder_id:
+		return BidderStatusWinning
+	default:
+		return BidderStatusOutbid
+	}
+}
+
+// bidsHidden tells whether the bids of an auction must stay hidden. Sealed
+// bids are only revealed once the auction ends; a cancelled sealed auction
+// never reveals them, as its seller could otherwise read them and relist
+// it.
+func bidsHidden(auctionType pgstore.AuctionType, cancelled bool, auctionEnd, now time.Time) bool {
+	return isSealed(auctionType) && (cancelled || auctionEnd.After(now))
+}
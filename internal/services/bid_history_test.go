@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+)
+
+func TestBidsHidden(t *testing.T) {
+	now := time.Now()
+	running := now.Add(time.Hour)
+	ended := now.Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		auctionType pgstore.AuctionType
+		cancelled   bool
+		auctionEnd  time.Time
+		want        bool
+	}{
+		{"english running", pgstore.AuctionTypeEnglish, false, running, false},
+		{"english cancelled", pgstore.AuctionTypeEnglish, true, running, false},
+		{"dutch running", pgstore.AuctionTypeDutch, false, running, false},
+		{"sealed first price running", pgstore.AuctionTypeSealedFirstPrice, false, running, true},
+		{"sealed second price running", pgstore.AuctionTypeSealedSecondPrice, false, running, true},
+		{"sealed ended", pgstore.AuctionTypeSealedFirstPrice, false, ended, false},
+		{"sealed ending now", pgstore.AuctionTypeSealedFirstPrice, false, now, false},
+		{"sealed cancelled while running", pgstore.AuctionTypeSealedFirstPrice, true, running, true},
+		{"sealed cancelled after its end", pgstore.AuctionTypeSealedSecondPrice, true, ended, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bidsHidden(tt.auctionType, tt.cancelled, tt.auctionEnd, now); got != tt.want {
+				t.Errorf("bidsHidden() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+)
+
+// IncrementRule decides how much a new bid must add to the current price.
+// Amounts are in the minor unit of the product currency.
+type IncrementRule interface {
+	Increment(current money.Amount) money.Amount
+}
+
+// FixedIncrement requires the same step regardless of the price.
+type FixedIncrement money.Amount
+
+func (f FixedIncrement) Increment(money.Amount) money.Amount {
+	return money.Amount(f)
+}
+
+// PercentIncrement requires a share of the current price, expressed in basis
+// points (hundredths of a percent), rounded up and never below Minimum.
+type PercentIncrement struct {
+	BasisPoints int64
+	Minimum     money.Amount
+}
+
+func (p PercentIncrement) Increment(current money.Amount) money.Amount {
+	increment := (int64(current)*p.BasisPoints + 9999) / 10000
+	return max(money.Amount(increment), p.Minimum, 1)
+}
+
+type IncrementStep struct {
+	From      money.Amount
+	Increment money.Amount
+}
+
+// IncrementLadder uses the step of the highest price band the current price
+// has reached. Steps must be sorted by From.
+type IncrementLadder []IncrementStep
+
+func (l IncrementLadder) Increment(current money.Amount) money.Amount {
+	increment := money.Amount(1)
+
+	for _, step := range l {
+		if current < step.From {
+			break
+		}
+		increment = step.Increment
+	}
+
+	return increment
+}
+
+// DefaultIncrementLadder is the global rule for products that do not set
+// their own increment.
+var DefaultIncrementLadder = IncrementLadder{
+	{From: 0, Increment: 5},
+	{From: 100, Increment: 25},
+	{From: 500, Increment: 50},
+	{From: 2500, Increment: 100},
+	{From: 10000, Increment: 250},
+	{From: 25000, Increment: 500},
+	{From: 50000, Increment: 1000},
+	{From: 100000, Increment: 2500},
+	{From: 250000, Increment: 5000},
+	{From: 500000, Increment: 10000},
+}
+
+var ErrBidBelowIncrement = errors.New("bid is below the minimum increment")
+
+// BidBelowIncrementError is returned when a bid beats the current price by
+// less than the product's increment. It matches ErrBidBelowIncrement.
+type BidBelowIncrementError struct {
+	MinimumNextBid money.Money
+}
+
+func (e *BidBelowIncrementError) Error() string {
+	return fmt.Sprintf("%s, the minimum bid is %s", ErrBidBelowIncrement, e.MinimumNextBid)
+}
+
+func (e *BidBelowIncrementError) Is(target error) bool {
+	return target == ErrBidBelowIncrement
+}
+
+// incrementRule is the increment product sets for itself, or the global
+// rule when it sets none.
+func incrementRule(product pgstore.Product, global IncrementRule) IncrementRule {
+	switch {
+	case product.BidIncrement != nil:
+		return FixedIncrement(*product.BidIncrement)
+	case product.BidIncrementBps.Valid:
+		return PercentIncrement{BasisPoints: int64(product.BidIncrementBps.Int32)}
+	default:
+		return global
+	}
+}
+
+// minimumNextBid is the lowest amount the next bid on product may have.
+func (bs *BidsService) minimumNextBid(product pgstore.Product, highestBid *pgstore.Bid) money.Money {
+	if highestBid == nil {
+		return minimumNextBid(product, nil, bs.increments)
+	}
+
+	return minimumNextBid(product, &highestBid.BidAmount, bs.increments)
+}
+
+func minimumNextBid(product pgstore.Product, highestBid *money.Amount, global IncrementRule) money.Money {
+	current := product.Baseprice
+
+	if highestBid != nil {
+		current = *highestBid
+	}
+
+	return money.New(current+incrementRule(product, global).Increment(current), product.Currency)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestIncrementRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    IncrementRule
+		current money.Amount
+		want    money.Amount
+	}{
+		{"fixed", FixedIncrement(50), 12345, 50},
+		{"percent", PercentIncrement{BasisPoints: 500}, 10000, 500},
+		{"percent rounds up", PercentIncrement{BasisPoints: 500}, 10001, 501},
+		{"percent of zero is at least one", PercentIncrement{BasisPoints: 500}, 0, 1},
+		{"percent never below minimum", PercentIncrement{BasisPoints: 100, Minimum: 25}, 1000, 25},
+		{"ladder below first step", IncrementLadder{{From: 100, Increment: 25}}, 99, 1},
+		{"ladder first band", DefaultIncrementLadder, 0, 5},
+		{"ladder just below a boundary", DefaultIncrementLadder, 99, 5},
+		{"ladder on a boundary", DefaultIncrementLadder, 100, 25},
+		{"ladder just above a boundary", DefaultIncrementLadder, 101, 25},
+		{"ladder top band", DefaultIncrementLadder, 10000000, 10000},
+		{"empty ladder", IncrementLadder{}, 500, 1},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.Increment(tt.current); got != tt.want {
+			t.Errorf("%s: Increment(%d) = %d, want %d", tt.name, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestMinimumNextBid(t *testing.T) {
+	fixed := money.Amount(30)
+	bs := &BidsService{increments: DefaultIncrementLadder}
+
+	tests := []struct {
+		name       string
+		product    pgstore.Product
+		highestBid *pgstore.Bid
+		want       money.Amount
+	}{
+		{"baseprice without bids", pgstore.Product{Baseprice: 100}, nil, 125},
+		{"highest bid", pgstore.Product{Baseprice: 100}, &pgstore.Bid{BidAmount: 2500}, 2600},
+		{"fixed increment", pgstore.Product{Baseprice: 100, BidIncrement: &fixed}, &pgstore.Bid{BidAmount: 200}, 230},
+		{"percent increment", pgstore.Product{Baseprice: 100, BidIncrementBps: pgtype.Int4{Int32: 1000, Valid: true}}, &pgstore.Bid{BidAmount: 1000}, 1100},
+	}
+
+	for _, tt := range tests {
+		tt.product.Currency = "BRL"
+
+		if got := bs.minimumNextBid(tt.product, tt.highestBid); got != money.New(tt.want, "BRL") {
+			t.Errorf("%s: minimumNextBid() = %v, want %d", tt.name, got, tt.want)
+		}
+	}
+}
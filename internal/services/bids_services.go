@@ -3,56 +3,506 @@ package services
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/andresilvase/gobid/internal/money"
 	"github.com/andresilvase/gobid/internal/store/pgstore"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// SoftClose extends an auction by Extension whenever a bid is placed within
+// Window of its end. A zero Window disables it.
+type SoftClose struct {
+	Window    time.Duration
+	Extension time.Duration
+}
+
 type BidsService struct {
-	pool    *pgxpool.Pool
-	queries *pgstore.Queries
+	pool       *pgxpool.Pool
+	queries    *pgstore.Queries
+	softClose  SoftClose
+	increments IncrementRule
 }
 
-func NewBidService(pool *pgxpool.Pool) BidsService {
+func NewBidService(pool *pgxpool.Pool, softClose SoftClose, increments IncrementRule) BidsService {
 	return BidsService{
-		pool:    pool,
-		queries: pgstore.New(pool),
+		pool:       pool,
+		queries:    pgstore.New(pool),
+		softClose:  softClose,
+		increments: increments,
 	}
 }
 
+// PlacedBids holds every visible bid produced by a single request and the
+// auction end once they were placed.
+type PlacedBids struct {
+	Bids       []pgstore.Bid
+	Currency   string
+	AuctionEnd time.Time
+	Extended   bool
+	// ReserveMet is nil when the product has no reserve price.
+	ReserveMet     *bool
+	MinimumNextBid money.Money
+	// Events are the room events recorded with the bids, already numbered.
+	Events []Message
+}
+
 var ErrBidIsTooLow = errors.New("bid value is too low")
+var ErrAuctionEnded = errors.New("auction has ended")
+var ErrAuctionCancelled = errors.New("auction has been cancelled")
+var ErrBidderIsSeller = errors.New("sellers cannot bid on their own products")
+var ErrCurrencyMismatch = errors.New("bid currency does not match the product currency")
+var ErrBuyNowUnavailable = errors.New("buy it now is not available for this auction")
+var ErrUnsupportedForAuctionType = errors.New("this action is not available for this auction type")
+
+// PlaceBid validates and stores a bid in a single transaction. The product row
+// is locked until the bid is committed, so concurrent bids on the same product
+// are placed one at a time. In sealed-bid auctions the bid replaces any
+// previous bid from the same bidder.
+//
+// The returned bids are every visible bid the call produced, in order: the
+// bid itself followed by any proxy bids placed in response to it.
+func (bs *BidsService) PlaceBid(ctx context.Context, product_id, bidder_id uuid.UUID, amount money.Money) (PlacedBids, error) {
+	return bs.inBidTx(ctx, product_id, bidder_id, amount, func(queries *pgstore.Queries, product pgstore.Product, highestBid *pgstore.Bid) ([]pgstore.Bid, error) {
+		if product.AuctionType == pgstore.AuctionTypeDutch {
+			return nil, ErrUnsupportedForAuctionType
+		}
+
+		if isSealed(product.AuctionType) {
+			return placeSealedBid(ctx, queries, product, bidder_id, amount.Amount)
+		}
+
+		if product.Baseprice >= amount.Amount || (highestBid != nil && highestBid.BidAmount >= amount.Amount) {
+			return nil, ErrBidIsTooLow
+		}
+
+		if minimumNextBid := bs.minimumNextBid(product, highestBid); amount.Amount < minimumNextBid.Amount {
+			return nil, &BidBelowIncrementError{MinimumNextBid: minimumNextBid}
+		}
+
+		bid, err := createBid(ctx, queries, product.ID, bidder_id, amount.Amount)
+
+		if err != nil {
+			return nil, err
+		}
+
+		proxyBids, err := bs.placeProxyBids(ctx, queries, product, &bid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return append([]pgstore.Bid{bid}, proxyBids...), nil
+	})
+}
+
+// PlaceProxyBid stores the maximum amount the bidder is willing to pay and
+// places the visible bids needed for the bidder to lead, never more than
+// the minimum increment above the competition.
+func (bs *BidsService) PlaceProxyBid(ctx context.Context, product_id, bidder_id uuid.UUID, maxAmount money.Money) (PlacedBids, error) {
+	return bs.inBidTx(ctx, product_id, bidder_id, maxAmount, func(queries *pgstore.Queries, product pgstore.Product, highestBid *pgstore.Bid) ([]pgstore.Bid, error) {
+		if product.AuctionType != pgstore.AuctionTypeEnglish {
+			return nil, ErrUnsupportedForAuctionType
+		}
+
+		if product.Baseprice >= maxAmount.Amount || (highestBid != nil && highestBid.BidAmount >= maxAmount.Amount) {
+			return nil, ErrBidIsTooLow
+		}
+
+		isLeading := highestBid != nil && highestBid.BidderID == bidder_id
+
+		if minimumNextBid := bs.minimumNextBid(product, highestBid); !isLeading && maxAmount.Amount < minimumNextBid.Amount {
+			return nil, &BidBelowIncrementError{MinimumNextBid: minimumNextBid}
+		}
+
+		_, err := queries.UpsertProxyBid(ctx, pgstore.UpsertProxyBidParams{
+			ProductID: product.ID,
+			BidderID:  bidder_id,
+			MaxAmount: maxAmount.Amount,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return bs.placeProxyBids(ctx, queries, product, highestBid)
+	})
+}
+
+type bidPlacement func(queries *pgstore.Queries, product pgstore.Product, highestBid *pgstore.Bid) ([]pgstore.Bid, error)
+
+// inBidTx locks the product, checks that the bidder may bid on it and runs
+// place inside the same transaction, extending the auction if the bids land
+// in the soft close window.
+func (bs *BidsService) inBidTx(ctx context.Context, product_id, bidder_id uuid.UUID, amount money.Money, place bidPlacement) (PlacedBids, error) {
+	tx, err := bs.pool.Begin(ctx)
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, highestBid, err := lockBiddableProduct(ctx, queries, product_id, bidder_id)
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	if amount.Currency != product.Currency {
+		return PlacedBids{}, ErrCurrencyMismatch
+	}
+
+	bids, err := place(queries, product, highestBid)
 
-func (bs *BidsService) PlaceBid(ctx context.Context, product_id, bidder_id uuid.UUID, amount float64) (pgstore.Bid, error) {
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	placed := PlacedBids{
+		Bids:           bids,
+		Currency:       product.Currency,
+		AuctionEnd:     product.AuctionEnd,
+		MinimumNextBid: bs.minimumNextBid(product, highestBid),
+	}
+
+	if len(bids) > 0 {
+		placed.MinimumNextBid = bs.minimumNextBid(product, &bids[len(bids)-1])
+	}
+
+	isEnglish := product.AuctionType == pgstore.AuctionTypeEnglish
+
+	if isEnglish && len(bids) > 0 && product.ReservePrice != nil {
+		reserveMet := bids[len(bids)-1].BidAmount >= *product.ReservePrice
+		placed.ReserveMet = &reserveMet
+	}
+
+	if isEnglish && len(bids) > 0 && bs.softClose.Window > 0 && time.Until(product.AuctionEnd) <= bs.softClose.Window {
+		placed.AuctionEnd = product.AuctionEnd.Add(bs.softClose.Extension)
+		placed.Extended = true
+
+		err := queries.UpdateProductAuctionEnd(ctx, pgstore.UpdateProductAuctionEndParams{
+			ID:         product.ID,
+			AuctionEnd: placed.AuctionEnd,
+		})
+
+		if err != nil {
+			return PlacedBids{}, err
+		}
+	}
+
+	if !isSealed(product.AuctionType) {
+		placed.Events, err = recordEvents(ctx, queries, product.ID, bidEvents(placed))
+
+		if err != nil {
+			return PlacedBids{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return PlacedBids{}, err
+	}
+
+	return placed, nil
+}
+
+// bidEvents are the messages telling the room about placed bids, in the
+// order they happened.
+func bidEvents(placed PlacedBids) []Message {
+	var events []Message
+
+	for _, bid := range placed.Bids {
+		bidAmount := money.New(bid.BidAmount, placed.Currency)
+
+		events = append(events, Message{
+			Message:        "A new bid was placed",
+			Amount:         &bidAmount,
+			Kind:           NewBidPlaced,
+			UserId:         bid.BidderID,
+			ReserveMet:     placed.ReserveMet,
+			MinimumNextBid: &placed.MinimumNextBid,
+		})
+	}
+
+	if placed.Extended {
+		events = append(events, Message{
+			Kind:       AuctionExtended,
+			Message:    "Auction has been extended",
+			AuctionEnd: &placed.AuctionEnd,
+		})
+	}
+
+	return events
+}
+
+// MinimumNextBid is the lowest amount a new bid on the product may have.
+func (bs *BidsService) MinimumNextBid(ctx context.Context, product_id uuid.UUID) (money.Money, error) {
 	product, err := bs.queries.GetProductById(ctx, product_id)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return pgstore.Bid{}, err
+			return money.Money{}, ErrProductNotFound
 		}
+		return money.Money{}, err
 	}
 
-	highestBid, err := bs.queries.GetHighestBidByProductId(ctx, product_id)
+	var highestBid *pgstore.Bid
+
+	bid, err := bs.queries.GetHighestBidByProductId(ctx, product_id)
+
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
-			return pgstore.Bid{}, err
+			return money.Money{}, err
+		}
+	} else {
+		highestBid = &bid
+	}
+
+	return bs.minimumNextBid(product, highestBid), nil
+}
+
+// lockBiddableProduct locks the product row for the rest of the transaction
+// and checks that bidder_id may bid on it. It also returns the current
+// highest bid, or nil when there is none.
+func lockBiddableProduct(ctx context.Context, queries *pgstore.Queries, product_id, bidder_id uuid.UUID) (pgstore.Product, *pgstore.Bid, error) {
+	product, err := queries.GetProductByIdForUpdate(ctx, product_id)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.Product{}, nil, ErrProductNotFound
+		}
+		return pgstore.Product{}, nil, err
+	}
+
+	if product.CancelledAt.Valid {
+		return pgstore.Product{}, nil, ErrAuctionCancelled
+	}
+
+	if product.IsSold || !product.AuctionEnd.After(time.Now()) {
+		return pgstore.Product{}, nil, ErrAuctionEnded
+	}
+
+	if product.SellerID == bidder_id {
+		return pgstore.Product{}, nil, ErrBidderIsSeller
+	}
+
+	highestBid, err := queries.GetHighestBidByProductId(ctx, product_id)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return product, nil, nil
 		}
+		return pgstore.Product{}, nil, err
+	}
+
+	return product, &highestBid, nil
+}
+
+// BuyNow places a bid at the product's buy-it-now price and ends the auction
+// immediately. It is only available while no bid has reached that price. The
+// bid is recorded as a room event like those of PlaceBid.
+func (bs *BidsService) BuyNow(ctx context.Context, product_id, buyer_id uuid.UUID) (PlacedBids, error) {
+	tx, err := bs.pool.Begin(ctx)
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, highestBid, err := lockBiddableProduct(ctx, queries, product_id, buyer_id)
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	if product.AuctionType != pgstore.AuctionTypeEnglish {
+		return PlacedBids{}, ErrUnsupportedForAuctionType
+	}
+
+	if product.BuyNowPrice == nil || (highestBid != nil && highestBid.BidAmount >= *product.BuyNowPrice) {
+		return PlacedBids{}, ErrBuyNowUnavailable
+	}
+
+	bid, err := createBid(ctx, queries, product_id, buyer_id, *product.BuyNowPrice)
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	placed := PlacedBids{
+		Bids:       []pgstore.Bid{bid},
+		Currency:   product.Currency,
+		AuctionEnd: time.Now(),
+	}
+
+	err = queries.UpdateProductAuctionEnd(ctx, pgstore.UpdateProductAuctionEndParams{
+		ID:         product_id,
+		AuctionEnd: placed.AuctionEnd,
+	})
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	price := money.New(bid.BidAmount, product.Currency)
+
+	// The winning bid is numbered like any other, so other bidders and
+	// reconnecting clients see it before the auction finishes.
+	placed.Events, err = recordEvents(ctx, queries, product_id, []Message{{
+		Kind:    NewBidPlaced,
+		Message: "The product was bought at its buy it now price",
+		Amount:  &price,
+		UserId:  buyer_id,
+	}})
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return PlacedBids{}, err
 	}
 
-	if product.Baseprice >= amount || highestBid.BidAmount >= amount {
-		return pgstore.Bid{}, ErrBidIsTooLow
+	return placed, nil
+}
+
+func isSealed(auctionType pgstore.AuctionType) bool {
+	return auctionType == pgstore.AuctionTypeSealedFirstPrice ||
+		auctionType == pgstore.AuctionTypeSealedSecondPrice
+}
+
+// placeSealedBid replaces the bidder's sealed bid. Sealed bids only need to
+// reach the base price since bidders cannot see each other's amounts.
+func placeSealedBid(ctx context.Context, queries *pgstore.Queries, product pgstore.Product, bidder_id uuid.UUID, amount money.Amount) ([]pgstore.Bid, error) {
+	if amount < product.Baseprice {
+		return nil, ErrBidIsTooLow
 	}
 
-	highestBid, err = bs.queries.CreateBid(ctx, pgstore.CreateBidParams{
+	err := queries.DeleteBidsByProductIdAndBidderId(ctx, pgstore.DeleteBidsByProductIdAndBidderIdParams{
+		ProductID: product.ID,
+		BidderID:  bidder_id,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	bid, err := createBid(ctx, queries, product.ID, bidder_id, amount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []pgstore.Bid{bid}, nil
+}
+
+func createBid(ctx context.Context, queries *pgstore.Queries, product_id, bidder_id uuid.UUID, amount money.Amount) (pgstore.Bid, error) {
+	bid, err := queries.CreateBid(ctx, pgstore.CreateBidParams{
 		ProductID: product_id,
 		BidderID:  bidder_id,
 		BidAmount: amount,
 	})
 
 	if err != nil {
+		var pgErr *pgconn.PgError
+
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "bids_auction_open" {
+			return pgstore.Bid{}, ErrAuctionEnded
+		}
+
 		return pgstore.Bid{}, err
 	}
 
-	return highestBid, nil
+	return bid, nil
+}
+
+func (bs *BidsService) placeProxyBids(ctx context.Context, queries *pgstore.Queries, product pgstore.Product, highestBid *pgstore.Bid) ([]pgstore.Bid, error) {
+	proxies, err := queries.ListProxyBidsByProductId(ctx, product.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var bids []pgstore.Bid
+
+	for _, params := range resolveProxyBids(product.Baseprice, highestBid, proxies, incrementRule(product, bs.increments)) {
+		bid, err := createBid(ctx, queries, params.ProductID, params.BidderID, params.BidAmount)
+
+		if err != nil {
+			return nil, err
+		}
+
+		bids = append(bids, bid)
+	}
+
+	return bids, nil
+}
+
+// resolveProxyBids works out which bids the proxies place in response to the
+// current highest bid. proxies must be ordered by priority: highest maximum
+// first, ties going to whoever set that maximum first.
+func resolveProxyBids(baseprice money.Amount, highestBid *pgstore.Bid, proxies []pgstore.ProxyBid, increments IncrementRule) []pgstore.CreateBidParams {
+	if len(proxies) == 0 {
+		return nil
+	}
+
+	current := baseprice
+	leader := uuid.Nil
+
+	if highestBid != nil {
+		current = highestBid.BidAmount
+		leader = highestBid.BidderID
+	}
+
+	var bids []pgstore.CreateBidParams
+
+	place := func(proxy pgstore.ProxyBid, amount money.Amount) {
+		bids = append(bids, pgstore.CreateBidParams{
+			ProductID: proxy.ProductID,
+			BidderID:  proxy.BidderID,
+			BidAmount: amount,
+		})
+		current = amount
+		leader = proxy.BidderID
+	}
+
+	top := proxies[0]
+
+	if len(proxies) > 1 {
+		runnerUp := proxies[1]
+
+		canOutbid := runnerUp.MaxAmount >= current+increments.Increment(current)
+		if runnerUp.BidderID == leader {
+			canOutbid = runnerUp.MaxAmount > current
+		}
+
+		if canOutbid && runnerUp.MaxAmount == top.MaxAmount {
+			place(top, top.MaxAmount)
+			return bids
+		}
+
+		if canOutbid {
+			place(runnerUp, runnerUp.MaxAmount)
+		}
+	}
+
+	if top.BidderID == leader {
+		return bids
+	}
+
+	amount := min(current+increments.Increment(current), top.MaxAmount)
+
+	if amount > current {
+		place(top, amount)
+	}
+
+	return bids
 }
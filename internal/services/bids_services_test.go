@@ -0,0 +1,99 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+)
+
+func TestResolveProxyBids(t *testing.T) {
+	product := uuid.New()
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	proxy := func(bidder uuid.UUID, max money.Amount) pgstore.ProxyBid {
+		return pgstore.ProxyBid{ProductID: product, BidderID: bidder, MaxAmount: max}
+	}
+
+	bid := func(bidder uuid.UUID, amount money.Amount) pgstore.CreateBidParams {
+		return pgstore.CreateBidParams{ProductID: product, BidderID: bidder, BidAmount: amount}
+	}
+
+	highest := func(bidder uuid.UUID, amount money.Amount) *pgstore.Bid {
+		return &pgstore.Bid{ProductID: product, BidderID: bidder, BidAmount: amount}
+	}
+
+	tests := []struct {
+		name       string
+		highestBid *pgstore.Bid
+		proxies    []pgstore.ProxyBid
+		want       []pgstore.CreateBidParams
+	}{
+		{
+			name: "no proxies",
+		},
+		{
+			name:    "single proxy opens one increment above baseprice",
+			proxies: []pgstore.ProxyBid{proxy(alice, 5000)},
+			want:    []pgstore.CreateBidParams{bid(alice, 1100)},
+		},
+		{
+			name:       "proxy already leading does not bid against itself",
+			highestBid: highest(alice, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 5000)},
+		},
+		{
+			name:       "proxy bids its maximum when the increment would exceed it",
+			highestBid: highest(carol, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 2050)},
+			want:       []pgstore.CreateBidParams{bid(alice, 2050)},
+		},
+		{
+			name:       "proxy at the current price cannot outbid",
+			highestBid: highest(carol, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 2000)},
+		},
+		{
+			name:       "runner-up bids its maximum and the top proxy outbids it",
+			highestBid: highest(carol, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 5000), proxy(bob, 3000)},
+			want:       []pgstore.CreateBidParams{bid(bob, 3000), bid(alice, 3100)},
+		},
+		{
+			name:       "top proxy is capped at its maximum",
+			highestBid: highest(carol, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 3050), proxy(bob, 3000)},
+			want:       []pgstore.CreateBidParams{bid(bob, 3000), bid(alice, 3050)},
+		},
+		{
+			name:       "equal maxima go to the proxy set first",
+			highestBid: highest(carol, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 3000), proxy(bob, 3000)},
+			want:       []pgstore.CreateBidParams{bid(alice, 3000)},
+		},
+		{
+			name:       "leading runner-up only needs to beat the current price",
+			highestBid: highest(bob, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 5000), proxy(bob, 2050)},
+			want:       []pgstore.CreateBidParams{bid(bob, 2050), bid(alice, 2150)},
+		},
+		{
+			name:       "runner-up below the next increment stays out",
+			highestBid: highest(carol, 2000),
+			proxies:    []pgstore.ProxyBid{proxy(alice, 5000), proxy(bob, 2050)},
+			want:       []pgstore.CreateBidParams{bid(alice, 2100)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveProxyBids(1000, tt.highestBid, tt.proxies, FixedIncrement(100))
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveProxyBids() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+)
+
+// DutchSchedule is the descending price clock of a dutch auction. The price
+// starts at StartPrice at StartsAt and drops by PriceDrop every Interval
+// until it reaches FloorPrice.
+type DutchSchedule struct {
+	StartPrice money.Amount
+	FloorPrice money.Amount
+	PriceDrop  money.Amount
+	Interval   time.Duration
+	StartsAt   time.Time
+}
+
+// NewDutchSchedule reads the schedule stored with a dutch auction product.
+func NewDutchSchedule(product pgstore.Product) (DutchSchedule, bool) {
+	if product.AuctionType != pgstore.AuctionTypeDutch ||
+		product.DutchFloorPrice == nil ||
+		product.DutchPriceDrop == nil ||
+		!product.DutchDropIntervalSeconds.Valid {
+		return DutchSchedule{}, false
+	}
+
+	return DutchSchedule{
+		StartPrice: product.Baseprice,
+		FloorPrice: *product.DutchFloorPrice,
+		PriceDrop:  *product.DutchPriceDrop,
+		Interval:   time.Duration(product.DutchDropIntervalSeconds.Int32) * time.Second,
+		StartsAt:   product.CreatedAt,
+	}, true
+}
+
+func (s DutchSchedule) drops(at time.Time) int64 {
+	if at.Before(s.StartsAt) {
+		return 0
+	}
+
+	return int64(at.Sub(s.StartsAt) / s.Interval)
+}
+
+// PriceAt is the asking price at the given time.
+func (s DutchSchedule) PriceAt(at time.Time) money.Amount {
+	price := s.StartPrice - money.Amount(s.drops(at))*s.PriceDrop
+	return max(price, s.FloorPrice)
+}
+
+// NextDropAt is when the price drops next after the given time. It returns
+// false once the price has reached the floor.
+func (s DutchSchedule) NextDropAt(at time.Time) (time.Time, bool) {
+	if s.PriceAt(at) <= s.FloorPrice {
+		return time.Time{}, false
+	}
+
+	return s.StartsAt.Add(time.Duration(s.drops(at)+1) * s.Interval), true
+}
+
+// AcceptPrice buys a dutch auction product at its current asking price. Only
+// the first buyer succeeds; the auction ends as soon as the price is
+// accepted.
+func (bs *BidsService) AcceptPrice(ctx context.Context, product_id, buyer_id uuid.UUID) (PlacedBids, error) {
+	tx, err := bs.pool.Begin(ctx)
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, _, err := lockBiddableProduct(ctx, queries, product_id, buyer_id)
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	schedule, ok := NewDutchSchedule(product)
+
+	if !ok {
+		return PlacedBids{}, ErrUnsupportedForAuctionType
+	}
+
+	now := time.Now()
+
+	bid, err := createBid(ctx, queries, product_id, buyer_id, schedule.PriceAt(now))
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	err = queries.UpdateProductAuctionEnd(ctx, pgstore.UpdateProductAuctionEndParams{
+		ID:         product_id,
+		AuctionEnd: now,
+	})
+
+	if err != nil {
+		return PlacedBids{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return PlacedBids{}, err
+	}
+
+	return PlacedBids{
+		Bids:       []pgstore.Bid{bid},
+		Currency:   product.Currency,
+		AuctionEnd: now,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestDutchSchedule(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	schedule := DutchSchedule{
+		StartPrice: 1000,
+		FloorPrice: 250,
+		PriceDrop:  100,
+		Interval:   time.Minute,
+		StartsAt:   start,
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		price    money.Amount
+		nextDrop time.Time
+		dropping bool
+	}{
+		{"before the start", start.Add(-time.Hour), 1000, start.Add(time.Minute), true},
+		{"at the start", start, 1000, start.Add(time.Minute), true},
+		{"just before the first drop", start.Add(time.Minute - time.Nanosecond), 1000, start.Add(time.Minute), true},
+		{"on the first drop", start.Add(time.Minute), 900, start.Add(2 * time.Minute), true},
+		{"one drop above the floor", start.Add(7 * time.Minute), 300, start.Add(8 * time.Minute), true},
+		{"clamped to the floor", start.Add(8 * time.Minute), 250, time.Time{}, false},
+		{"long after the floor", start.Add(24 * time.Hour), 250, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := schedule.PriceAt(tt.at); got != tt.price {
+			t.Errorf("%s: PriceAt() = %d, want %d", tt.name, got, tt.price)
+		}
+
+		nextDrop, dropping := schedule.NextDropAt(tt.at)
+
+		if dropping != tt.dropping || !nextDrop.Equal(tt.nextDrop) {
+			t.Errorf("%s: NextDropAt() = %v, %v, want %v, %v", tt.name, nextDrop, dropping, tt.nextDrop, tt.dropping)
+		}
+	}
+}
+
+func TestNewDutchSchedule(t *testing.T) {
+	floor, drop := money.Amount(250), money.Amount(100)
+	interval := pgtype.Int4{Int32: 60, Valid: true}
+
+	tests := []struct {
+		name    string
+		product pgstore.Product
+		ok      bool
+	}{
+		{"dutch", pgstore.Product{AuctionType: pgstore.AuctionTypeDutch, DutchFloorPrice: &floor, DutchPriceDrop: &drop, DutchDropIntervalSeconds: interval}, true},
+		{"english", pgstore.Product{AuctionType: pgstore.AuctionTypeEnglish, DutchFloorPrice: &floor, DutchPriceDrop: &drop, DutchDropIntervalSeconds: interval}, false},
+		{"missing floor", pgstore.Product{AuctionType: pgstore.AuctionTypeDutch, DutchPriceDrop: &drop, DutchDropIntervalSeconds: interval}, false},
+		{"missing interval", pgstore.Product{AuctionType: pgstore.AuctionTypeDutch, DutchFloorPrice: &floor, DutchPriceDrop: &drop}, false},
+	}
+
+	for _, tt := range tests {
+		schedule, ok := NewDutchSchedule(tt.product)
+
+		if ok != tt.ok {
+			t.Errorf("%s: NewDutchSchedule() ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+
+		if ok && schedule.Interval != time.Minute {
+			t.Errorf("%s: Interval = %v, want 1m", tt.name, schedule.Interval)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Mailer sends the emails of the application.
+type Mailer interface {
+	// SendEmailVerification sends to email the token that proves the user
+	// owns it.
+	SendEmailVerification(ctx context.Context, email, token string) error
+}
+
+// LogMailer logs emails instead of sending them, for development.
+type LogMailer struct{}
+
+func (LogMailer) SendEmailVerification(ctx context.Context, email, token string) error {
+	slog.Info("Email verification", "email", email, "token", token)
+	return nil
+}
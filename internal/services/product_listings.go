@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Product statuses, as shown in listings and used to filter them.
+const (
+	ProductStatusActive = "active"
+	// Ended products include the sold ones.
+	ProductStatusEnded     = "ended"
+	ProductStatusSold      = "sold"
+	ProductStatusCancelled = "cancelled"
+)
+
+// Sort orders of product listings.
+const (
+	SortEndingSoonest = "ending_soonest"
+	SortNewest        = "newest"
+	SortMostBids      = "most_bids"
+	SortHighestPrice  = "highest_price"
+	// SortRelevance puts the best matches of a search first.
+	SortRelevance = "relevance"
+)
+
+// ProductListing is the public view of a product. It leaves out what only
+// the seller may see, such as the reserve price.
+type ProductListing struct {
+	Id          uuid.UUID           `json:"id"`
+	SellerId    uuid.UUID           `json:"seller_id"`
+	ProductName string              `json:"product_name"`
+	Description string              `json:"description"`
+	AuctionType pgstore.AuctionType `json:"auction_type"`
+	Status      string              `json:"status"`
+	Baseprice   money.Money         `json:"baseprice"`
+	// CurrentPrice is the highest bid, the asking price of a dutch auction,
+	// or baseprice while the bids of a sealed auction stay hidden.
+	CurrentPrice money.Money `json:"current_price"`
+	BidCount     int64       `json:"bid_count"`
+	// MinimumNextBid is only set for active auctions that take increasing
+	// bids, i.e. not for sealed or dutch ones.
+	MinimumNextBid *money.Money `json:"minimum_next_bid,omitempty"`
+	BuyNowPrice    *money.Money `json:"buy_now_price,omitempty"`
+	AuctionEnd     time.Time    `json:"auction_end"`
+	CreatedAt      time.Time    `json:"created_at"`
+	// Rank and Snippet are only set in search results. Snippet is an HTML
+	// escaped excerpt of the description with the matched words wrapped in
+	// <mark> tags.
+	Rank    float32 `json:"rank,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// ProductFilter narrows and orders a product listing. Unset fields match
+// every product; MinPrice and MaxPrice also restrict the listing to their
+// currency.
+type ProductFilter struct {
+	// Search is what the user typed in the search box; see searchQuery.
+	Search       string
+	Status       string
+	SellerId     *uuid.UUID
+	MinPrice     *money.Money
+	MaxPrice     *money.Money
+	EndingBefore *time.Time
+	Sort         string
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int32
+}
+
+type ProductPage struct {
+	Products []ProductListing `json:"products"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrEmptySearch   = errors.New("search has no words to look for")
+)
+
+// productCursor is where a page ended. It is only valid for the sort order
+// it was made for.
+type productCursor struct {
+	Sort string    `json:"s"`
+	Key  int64     `json:"k"`
+	Id   uuid.UUID `json:"i"`
+}
+
+func (c productCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(raw string) (productCursor, error) {
+	var cursor productCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+
+	if err != nil {
+		return productCursor{}, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return productCursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func (ps *ProductService) ListProducts(ctx context.Context, filter ProductFilter) (ProductPage, error) {
+	params := pgstore.ListProductsParams{
+		SortOrder: filter.Sort,
+		// One more than asked for tells whether there is a next page.
+		MaxProducts: filter.Limit + 1,
+	}
+
+	if filter.Search != "" {
+		query := searchQuery(filter.Search)
+
+		if query == "" {
+			return ProductPage{}, ErrEmptySearch
+		}
+
+		params.Search = pgtype.Text{String: query, Valid: true}
+	}
+
+	if filter.Status != "" {
+		params.Status = pgtype.Text{String: filter.Status, Valid: true}
+	}
+
+	if filter.SellerId != nil {
+		params.SellerID = uuid.NullUUID{UUID: *filter.SellerId, Valid: true}
+	}
+
+	if filter.EndingBefore != nil {
+		params.EndingBefore = pgtype.Timestamptz{Time: *filter.EndingBefore, Valid: true}
+	}
+
+	if filter.MinPrice != nil {
+		params.MinPrice = pgtype.Int8{Int64: int64(filter.MinPrice.Amount), Valid: true}
+		params.Currency = pgtype.Text{String: filter.MinPrice.Currency, Valid: true}
+	}
+
+	if filter.MaxPrice != nil {
+		params.MaxPrice = pgtype.Int8{Int64: int64(filter.MaxPrice.Amount), Valid: true}
+		params.Currency = pgtype.Text{String: filter.MaxPrice.Currency, Valid: true}
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeProductCursor(filter.Cursor)
+
+		if err != nil || cursor.Sort != filter.Sort {
+			return ProductPage{}, ErrInvalidCursor
+		}
+
+		params.AfterKey = pgtype.Int8{Int64: cursor.Key, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: cursor.Id, Valid: true}
+	}
+
+	rows, err := ps.queries.ListProducts(ctx, params)
+
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	page := ProductPage{Products: make([]ProductListing, 0, len(rows))}
+
+	if len(rows) > int(filter.Limit) {
+		rows = rows[:filter.Limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = productCursor{Sort: filter.Sort, Key: last.SortKey, Id: last.ID}.encode()
+	}
+
+	now := time.Now()
+
+	for _, row := range rows {
+		listing := ps.rowListing(row, now)
+
+		if params.Search.Valid {
+			listing.Rank = row.Rank
+			listing.Snippet = searchSnippet(row.Snippet)
+		}
+
+		page.Products = append(page.Products, listing)
+	}
+
+	return page, nil
+}
+
+// GetProductListing reads the public view of a product with its current
+// price and bid count.
+func (ps *ProductService) GetProductListing(ctx context.Context, productId uuid.UUID) (ProductListing, error) {
+	product, err := ps.GetProductById(ctx, productId)
+
+	if err != nil {
+		return ProductListing{}, err
+	}
+
+	stats, err := ps.queries.GetBidStatsByProductId(ctx, productId)
+
+	if err != nil {
+		return ProductListing{}, err
+	}
+
+	var highestBid *money.Amount
+
+	bid, err := ps.queries.GetHighestBidByProductId(ctx, productId)
+
+	switch {
+	case err == nil:
+		highestBid = &bid.BidAmount
+	case !errors.Is(err, pgx.ErrNoRows):
+		return ProductListing{}, err
+	}
+
+	now := time.Now()
+
+	listing := newProductListing(product, stats.BidCount, now)
+	listing.CurrentPrice = money.New(currentPrice(product, highestBid, now), product.Currency)
+	listing.MinimumNextBid = ps.listingMinimumNextBid(product, highestBid, listing.Status)
+
+	return listing, nil
+}
+
+// listingMinimumNextBid is the minimum next bid shown with an active auction
+// that takes increasing bids, or nil for any other product.
+func (ps *ProductService) listingMinimumNextBid(product pgstore.Product, highestBid *money.Amount, status string) *money.Money {
+	if status != ProductStatusActive || isSealed(product.AuctionType) || product.AuctionType == pgstore.AuctionTypeDutch {
+		return nil
+	}
+
+	next := minimumNextBid(product, highestBid, ps.increments)
+
+	return &next
+}
+
+// rowListing is the public view of a ListProducts row.
+func (ps *ProductService) rowListing(row pgstore.ListProductsRow, now time.Time) ProductListing {
+	product := listedProduct(row)
+
+	var highestBid *money.Amount
+
+	if row.BidCount > 0 {
+		amount := money.Amount(row.HighestBid)
+		highestBid = &amount
+	}
+
+	listing := newProductListing(product, row.BidCount, now)
+	listing.CurrentPrice = money.New(money.Amount(row.CurrentPrice), row.Currency)
+	listing.MinimumNextBid = ps.listingMinimumNextBid(product, highestBid, listing.Status)
+
+	return listing
+}
+
+// listedProduct is the product of a ListProducts row.
+func listedProduct(row pgstore.ListProductsRow) pgstore.Product {
+	return pgstore.Product{
+		ID:                       row.ID,
+		SellerID:                 row.SellerID,
+		ProductName:              row.ProductName,
+		Description:              row.Description,
+		Baseprice:                row.Baseprice,
+		AuctionEnd:               row.AuctionEnd,
+		IsSold:                   row.IsSold,
+		CreatedAt:                row.CreatedAt,
+		UpdatedAt:                row.UpdatedAt,
+		Currency:                 row.Currency,
+		ReservePrice:             row.ReservePrice,
+		BuyNowPrice:              row.BuyNowPrice,
+		BidIncrement:             row.BidIncrement,
+		BidIncrementBps:          row.BidIncrementBps,
+		AuctionType:              row.AuctionType,
+		DutchFloorPrice:          row.DutchFloorPrice,
+		DutchPriceDrop:           row.DutchPriceDrop,
+		DutchDropIntervalSeconds: row.DutchDropIntervalSeconds,
+		CancelledAt:              row.CancelledAt,
+	}
+}
+
+func newProductListing(product pgstore.Product, bidCount int64, now time.Time) ProductListing {
+	listing := ProductListing{
+		Id:          product.ID,
+		SellerId:    product.SellerID,
+		ProductName: product.ProductName,
+		Description: product.Description,
+		AuctionType: product.AuctionType,
+		Status:      productStatus(product, now),
+		Baseprice:   money.New(product.Baseprice, product.Currency),
+		BidCount:    bidCount,
+		AuctionEnd:  product.AuctionEnd,
+		CreatedAt:   product.CreatedAt,
+	}
+
+	if product.BuyNowPrice != nil {
+		buyNowPrice := money.New(*product.BuyNowPrice, product.Currency)
+		listing.BuyNowPrice = &buyNowPrice
+	}
+
+	return listing
+}
+
+func productStatus(product pgstore.Product, now time.Time) string {
+	switch {
+	case product.CancelledAt.Valid:
+		return ProductStatusCancelled
+	case product.IsSold:
+		return ProductStatusSold
+	case !product.AuctionEnd.After(now):
+		return ProductStatusEnded
+	default:
+		return ProductStatusActive
+	}
+}
+
+// currentPrice is the price bidders see, computed like current_price in the
+// ListProducts query.
+func currentPrice(product pgstore.Product, highestBid *money.Amount, now time.Time) money.Amount {
+	if isSealed(product.AuctionType) {
+		return product.Baseprice
+	}
+
+	if highestBid != nil {
+		return *highestBid
+	}
+
+	if schedule, ok := NewDutchSchedule(product); ok {
+		return schedule.PriceAt(now)
+	}
+
+	return product.Baseprice
+}
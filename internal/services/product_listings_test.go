@@ -0,0 +1,128 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestListedProductStatus(t *testing.T) {
+	now := time.Now()
+	cancelled := pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}
+
+	tests := []struct {
+		name string
+		row  pgstore.ListProductsRow
+		want string
+	}{
+		{
+			name: "active",
+			row:  pgstore.ListProductsRow{AuctionEnd: now.Add(time.Hour)},
+			want: ProductStatusActive,
+		},
+		{
+			name: "ended",
+			row:  pgstore.ListProductsRow{AuctionEnd: now.Add(-time.Hour)},
+			want: ProductStatusEnded,
+		},
+		{
+			name: "sold",
+			row:  pgstore.ListProductsRow{AuctionEnd: now.Add(-time.Hour), IsSold: true},
+			want: ProductStatusSold,
+		},
+		{
+			name: "cancelled before its end",
+			row:  pgstore.ListProductsRow{AuctionEnd: now.Add(time.Hour), CancelledAt: cancelled},
+			want: ProductStatusCancelled,
+		},
+		{
+			name: "cancelled after its end",
+			row:  pgstore.ListProductsRow{AuctionEnd: now.Add(-time.Minute), CancelledAt: cancelled},
+			want: ProductStatusCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := productStatus(listedProduct(tt.row), now); got != tt.want {
+				t.Errorf("status = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowListingMinimumNextBid(t *testing.T) {
+	now := time.Now()
+	fixed := money.Amount(30)
+	ps := &ProductService{increments: DefaultIncrementLadder}
+
+	tests := []struct {
+		name string
+		row  pgstore.ListProductsRow
+		want *money.Amount
+	}{
+		{
+			name: "baseprice without bids",
+			row:  pgstore.ListProductsRow{Baseprice: 100, CurrentPrice: 100},
+			want: amountPtr(125),
+		},
+		{
+			name: "highest bid",
+			row:  pgstore.ListProductsRow{Baseprice: 100, BidCount: 3, HighestBid: 2500, CurrentPrice: 2500},
+			want: amountPtr(2600),
+		},
+		{
+			name: "product increment",
+			row:  pgstore.ListProductsRow{Baseprice: 100, BidIncrement: &fixed, BidCount: 1, HighestBid: 200, CurrentPrice: 200},
+			want: amountPtr(230),
+		},
+		{
+			name: "ended",
+			row:  pgstore.ListProductsRow{Baseprice: 100, AuctionEnd: now.Add(-time.Hour)},
+		},
+		{
+			name: "cancelled",
+			row:  pgstore.ListProductsRow{Baseprice: 100, CancelledAt: pgtype.Timestamptz{Time: now, Valid: true}},
+		},
+		{
+			name: "sealed",
+			row:  pgstore.ListProductsRow{Baseprice: 100, AuctionType: pgstore.AuctionTypeSealedFirstPrice},
+		},
+		{
+			name: "dutch",
+			row:  pgstore.ListProductsRow{Baseprice: 100, AuctionType: pgstore.AuctionTypeDutch},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.row.Currency = "BRL"
+
+			if tt.row.AuctionType == "" {
+				tt.row.AuctionType = pgstore.AuctionTypeEnglish
+			}
+
+			if tt.row.AuctionEnd.IsZero() {
+				tt.row.AuctionEnd = now.Add(time.Hour)
+			}
+
+			got := ps.rowListing(tt.row, now).MinimumNextBid
+
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("minimum next bid = %v, want none", *got)
+			case tt.want != nil && got == nil:
+				t.Errorf("minimum next bid is missing, want %d", *tt.want)
+			case tt.want != nil && *got != money.New(*tt.want, "BRL"):
+				t.Errorf("minimum next bid = %v, want %d", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func amountPtr(amount money.Amount) *money.Amount {
+	return &amount
+}
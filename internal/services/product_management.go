@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/andresilvase/gobid/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrNotSeller          = errors.New("only the seller can manage this product")
+	ErrProductHasBids     = errors.New("prices, name and end time cannot be changed after the first bid")
+	ErrNotRelistable      = errors.New("only ended auctions that did not sell can be relisted")
+	ErrAlreadyRelisted    = errors.New("product has already been relisted")
+	ErrInvalidProductEdit = errors.New("invalid product edit")
+)
+
+// InvalidProductEditError lists the fields of an edit that do not fit the
+// product, e.g. a price in another currency.
+type InvalidProductEditError struct {
+	Problems validator.Evaluator
+}
+
+func (e *InvalidProductEditError) Error() string {
+	return ErrInvalidProductEdit.Error()
+}
+
+func (e *InvalidProductEditError) Is(target error) bool {
+	return target == ErrInvalidProductEdit
+}
+
+// ProductEdit holds the fields a seller changes; nil fields are kept.
+// Setting one of BidIncrement and BidIncrementBps clears the other.
+type ProductEdit struct {
+	ProductName     *string
+	Description     *string
+	Baseprice       *money.Money
+	ReservePrice    *money.Money
+	BuyNowPrice     *money.Money
+	BidIncrement    *money.Money
+	BidIncrementBps *int32
+	AuctionEnd      *time.Time
+}
+
+// onlyDescription tells whether the edit may be applied whatever the state
+// of the auction.
+func (e ProductEdit) onlyDescription() bool {
+	return e.ProductName == nil && e.Baseprice == nil && e.ReservePrice == nil &&
+		e.BuyNowPrice == nil && e.BidIncrement == nil && e.BidIncrementBps == nil &&
+		e.AuctionEnd == nil
+}
+
+// UpdateProduct applies a seller's edit. The description can be changed at
+// any time; everything else only while the auction is open and has no bids,
+// so no bid was placed under different terms.
+func (ps *ProductService) UpdateProduct(ctx context.Context, productId, sellerId uuid.UUID, edit ProductEdit) (pgstore.Product, error) {
+	tx, err := ps.pool.Begin(ctx)
+
+	if err != nil {
+		return pgstore.Product{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := ps.queries.WithTx(tx)
+
+	product, err := lockSellerProduct(ctx, queries, productId, sellerId)
+
+	if err != nil {
+		return pgstore.Product{}, err
+	}
+
+	if product.CancelledAt.Valid {
+		return pgstore.Product{}, ErrAuctionCancelled
+	}
+
+	if !edit.onlyDescription() {
+		if product.IsSold || !product.AuctionEnd.After(time.Now()) {
+			return pgstore.Product{}, ErrAuctionEnded
+		}
+
+		stats, err := queries.GetBidStatsByProductId(ctx, productId)
+
+		if err != nil {
+			return pgstore.Product{}, err
+		}
+
+		if stats.BidCount > 0 {
+			return pgstore.Product{}, ErrProductHasBids
+		}
+	}
+
+	params, err := applyProductEdit(product, edit)
+
+	if err != nil {
+		return pgstore.Product{}, err
+	}
+
+	product, err = queries.UpdateProduct(ctx, params)
+
+	if err != nil {
+		return pgstore.Product{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return pgstore.Product{}, err
+	}
+
+	return product, nil
+}
+
+// applyProductEdit merges an edit into the product and checks the prices
+// still fit together, as they are checked when the product is created.
+func applyProductEdit(product pgstore.Product, edit ProductEdit) (pgstore.UpdateProductParams, error) {
+	params := pgstore.UpdateProductParams{
+		ID:              product.ID,
+		ProductName:     product.ProductName,
+		Description:     product.Description,
+		Baseprice:       product.Baseprice,
+		ReservePrice:    product.ReservePrice,
+		BuyNowPrice:     product.BuyNowPrice,
+		BidIncrement:    product.BidIncrement,
+		BidIncrementBps: product.BidIncrementBps,
+		AuctionEnd:      product.AuctionEnd,
+	}
+
+	var val validator.Evaluator
+
+	amount := func(field string, price *money.Money) *money.Amount {
+		val.CheckField(price.Currency == product.Currency, field, "must use the currency of the product")
+		return &price.Amount
+	}
+
+	if edit.ProductName != nil {
+		params.ProductName = *edit.ProductName
+	}
+
+	if edit.Description != nil {
+		params.Description = *edit.Description
+	}
+
+	if edit.Baseprice != nil {
+		params.Baseprice = *amount("baseprice", edit.Baseprice)
+	}
+
+	if edit.ReservePrice != nil {
+		params.ReservePrice = amount("reserve_price", edit.ReservePrice)
+	}
+
+	if edit.BuyNowPrice != nil {
+		params.BuyNowPrice = amount("buy_now_price", edit.BuyNowPrice)
+	}
+
+	if edit.BidIncrement != nil {
+		params.BidIncrement = amount("bid_increment", edit.BidIncrement)
+		params.BidIncrementBps = pgtype.Int4{}
+	}
+
+	if edit.BidIncrementBps != nil {
+		params.BidIncrement = nil
+		params.BidIncrementBps = pgtype.Int4{Int32: *edit.BidIncrementBps, Valid: true}
+	}
+
+	if edit.AuctionEnd != nil {
+		params.AuctionEnd = *edit.AuctionEnd
+	}
+
+	if product.AuctionType != pgstore.AuctionTypeEnglish && edit.BuyNowPrice != nil {
+		return pgstore.UpdateProductParams{}, ErrUnsupportedForAuctionType
+	}
+
+	// The price clock of a running dutch auction starts from its baseprice,
+	// so the baseprice cannot change either.
+	if product.AuctionType == pgstore.AuctionTypeDutch &&
+		(edit.Baseprice != nil || edit.ReservePrice != nil || edit.BidIncrement != nil || edit.BidIncrementBps != nil) {
+		return pgstore.UpdateProductParams{}, ErrUnsupportedForAuctionType
+	}
+
+	if params.ReservePrice != nil {
+		val.CheckField(*params.ReservePrice >= params.Baseprice, "reserve_price", "must be greater than or equal to baseprice")
+	}
+
+	if params.BuyNowPrice != nil {
+		val.CheckField(*params.BuyNowPrice > params.Baseprice, "buy_now_price", "must be greater than baseprice")
+
+		if params.ReservePrice != nil {
+			val.CheckField(*params.BuyNowPrice >= *params.ReservePrice, "buy_now_price", "must be greater than or equal to reserve_price")
+		}
+	}
+
+	if len(val) > 0 {
+		return pgstore.UpdateProductParams{}, &InvalidProductEditError{Problems: val}
+	}
+
+	return params, nil
+}
+
+// CancelProduct withdraws an open auction. Its bids are kept but can no
+// longer win, and the auction is never settled.
+func (ps *ProductService) CancelProduct(ctx context.Context, productId, sellerId uuid.UUID) error {
+	tx, err := ps.pool.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := ps.queries.WithTx(tx)
+
+	product, err := lockSellerProduct(ctx, queries, productId, sellerId)
+
+	if err != nil {
+		return err
+	}
+
+	if product.CancelledAt.Valid {
+		return ErrAuctionCancelled
+	}
+
+	if product.IsSold || !product.AuctionEnd.After(time.Now()) {
+		return ErrAuctionEnded
+	}
+
+	if err := queries.CancelProduct(ctx, productId); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RelistProduct starts a new auction of a product that was cancelled or
+// ended unsold, with the same terms and a new end. A product is relisted
+// at most once; its new auction can be relisted in turn.
+func (ps *ProductService) RelistProduct(ctx context.Context, productId, sellerId uuid.UUID, auctionEnd time.Time) (uuid.UUID, error) {
+	tx, err := ps.pool.Begin(ctx)
+
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := ps.queries.WithTx(tx)
+
+	product, err := lockSellerProduct(ctx, queries, productId, sellerId)
+
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	if !product.CancelledAt.Valid {
+		// An ended auction is only known to be unsold once it is settled.
+		result, err := queries.GetAuctionResultByProductId(ctx, productId)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.UUID{}, ErrNotRelistable
+		}
+
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+
+		if result.IsSold {
+			return uuid.UUID{}, ErrNotRelistable
+		}
+	}
+
+	relistedId, err := queries.RelistProduct(ctx, pgstore.RelistProductParams{
+		AuctionEnd: auctionEnd,
+		ID:         productId,
+	})
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return uuid.UUID{}, ErrAlreadyRelisted
+		}
+
+		return uuid.UUID{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return relistedId, nil
+}
+
+// lockSellerProduct locks the product row for the rest of the transaction
+// and checks that sellerId owns it.
+func lockSellerProduct(ctx context.Context, queries *pgstore.Queries, productId, sellerId uuid.UUID) (pgstore.Product, error) {
+	product, err := queries.GetProductByIdForUpdate(ctx, productId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.Product{}, ErrProductNotFound
+		}
+		return pgstore.Product{}, err
+	}
+
+	if product.SellerID != sellerId {
+		return pgstore.Product{}, ErrNotSeller
+	}
+
+	return product, nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// ts_headline wraps the matched words of a snippet in these private use
+// characters, which searchSnippet turns into <mark> tags once the
+// description is escaped.
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+)
+
+// searchQuery turns what a user typed into a tsquery. Every word must
+// match, words in double quotes must match as a phrase and a word ending in
+// * matches any word it starts, so `"vintage camera" len*` becomes
+// `(vintage <-> camera) & len:*`. Anything but letters and digits is
+// dropped, which keeps the tsquery syntax out of users' hands. It returns an
+// empty string when there is nothing left to look for.
+func searchQuery(raw string) string {
+	var terms []string
+
+	for i, part := range strings.Split(raw, `"`) {
+		words := searchWords(part)
+
+		// Odd parts are between quotes; an unclosed quote runs to the end.
+		if i%2 == 1 && len(words) > 1 {
+			terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+			continue
+		}
+
+		terms = append(terms, words...)
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+func searchWords(text string) []string {
+	var words []string
+
+	for _, field := range strings.Fields(text) {
+		lexemes := strings.FieldsFunc(strings.ToLower(field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		if len(lexemes) > 0 && strings.HasSuffix(field, "*") {
+			lexemes[len(lexemes)-1] += ":*"
+		}
+
+		words = append(words, lexemes...)
+	}
+
+	return words
+}
+
+// searchSnippet turns a headline of a seller's description into HTML that is
+// safe to show: the description is escaped and only the matches are marked.
+func searchSnippet(headline string) string {
+	return strings.NewReplacer(
+		headlineStart, "<mark>",
+		headlineStop, "</mark>",
+	).Replace(html.EscapeString(headline))
+}
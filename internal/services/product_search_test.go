@@ -0,0 +1,69 @@
+package services
+
+import "testing"
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "empty", raw: "", want: ""},
+		{name: "only spaces", raw: "   ", want: ""},
+		{name: "single word", raw: "camera", want: "camera"},
+		{name: "words are lowered", raw: "Vintage CAMERA", want: "vintage & camera"},
+		{name: "phrase", raw: `"vintage camera"`, want: "(vintage <-> camera)"},
+		{name: "single word phrase", raw: `"camera"`, want: "camera"},
+		{name: "phrase and prefix", raw: `"vintage camera" len*`, want: "(vintage <-> camera) & len:*"},
+		{name: "unclosed quote runs to the end", raw: `lens "old film`, want: "lens & (old <-> film)"},
+		{name: "prefix", raw: "cam*", want: "cam:*"},
+		{name: "prefix inside phrase", raw: `"old cam*"`, want: "(old <-> cam:*)"},
+		{name: "lone star", raw: "*", want: ""},
+		{name: "punctuation splits words", raw: "f/1.8", want: "f & 1 & 8"},
+		{name: "tsquery operators are dropped", raw: "a & !b | (c:*)", want: "a & b & c"},
+		{name: "quotes and colons are dropped", raw: `it's:A`, want: "it & s & a"},
+		{name: "unicode letters are kept", raw: "Câmera Ünïcode", want: "câmera & ünïcode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchQuery(tt.raw); got != tt.want {
+				t.Errorf("searchQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		headline string
+		want     string
+	}{
+		{name: "empty", headline: "", want: ""},
+		{name: "plain text", headline: "an old camera", want: "an old camera"},
+		{
+			name:     "matches are marked",
+			headline: "an old " + headlineStart + "camera" + headlineStop,
+			want:     "an old <mark>camera</mark>",
+		},
+		{
+			name:     "markup is escaped",
+			headline: `<script>alert("x")</script> ` + headlineStart + "camera" + headlineStop,
+			want:     "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <mark>camera</mark>",
+		},
+		{
+			name:     "seller marks are escaped",
+			headline: "<mark>camera</mark> & lens",
+			want:     "&lt;mark&gt;camera&lt;/mark&gt; &amp; lens",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchSnippet(tt.headline); got != tt.want {
+				t.Errorf("searchSnippet(%q) = %q, want %q", tt.headline, got, tt.want)
+			}
+		})
+	}
+}
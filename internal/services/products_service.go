@@ -13,12 +13,16 @@ import (
 type ProductService struct {
 	pool    *pgxpool.Pool
 	queries *pgstore.Queries
+	// increments is the global increment rule, used to show the minimum next
+	// bid of products that do not set their own.
+	increments IncrementRule
 }
 
-func NewProductService(pool *pgxpool.Pool) ProductService {
+func NewProductService(pool *pgxpool.Pool, increments IncrementRule) ProductService {
 	return ProductService{
-		pool:    pool,
-		queries: pgstore.New(pool),
+		pool:       pool,
+		queries:    pgstore.New(pool),
+		increments: increments,
 	}
 }
 
@@ -40,3 +44,7 @@ func (ps *ProductService) GetProductById(ctx context.Context, productId uuid.UUI
 
 	return product, nil
 }
+
+func (ps *ProductService) ListOpenAuctions(ctx context.Context) ([]pgstore.Product, error) {
+	return ps.queries.ListOpenAuctions(ctx)
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/google/uuid"
+)
+
+// Websocket subprotocols, newest first. Clients that do not ask for one get
+// the legacy protocol, which sends Message as is with numeric kinds.
+const (
+	ProtocolV2     = "gobid.v2"
+	ProtocolLegacy = "gobid.v1"
+
+	ProtocolVersion = 2
+)
+
+var Subprotocols = []string{ProtocolV2, ProtocolLegacy}
+
+// legacyKinds are the numeric kinds of the legacy protocol, indexed by
+// their wire value.
+var legacyKinds = []Messagekind{
+	PlaceBid,
+	SuccessfullyPlacedBid,
+	FailedToPlaceBid,
+	InvalidJSON,
+	NewBidPlaced,
+	AuctionFinished,
+	AuctionExtended,
+	BuyNow,
+	SuccessfullyBoughtNow,
+	FailedToBuyNow,
+	PriceDropped,
+	AcceptPrice,
+	SuccessfullyAcceptedPrice,
+	FailedToAcceptPrice,
+	RoomSnapshot,
+	TimeSync,
+	ClosingPhase,
+	ServerRestarting,
+	AuctionUpdated,
+	AuctionCancelled,
+}
+
+func (k Messagekind) legacyValue() int {
+	for value, kind := range legacyKinds {
+		if kind == k {
+			return value
+		}
+	}
+	return -1
+}
+
+// UnmarshalJSON also accepts the numeric kinds of the legacy protocol.
+func (k *Messagekind) UnmarshalJSON(data []byte) error {
+	var value int
+
+	if err := json.Unmarshal(data, &value); err == nil {
+		if value < 0 || value >= len(legacyKinds) {
+			return fmt.Errorf("unknown message kind %d", value)
+		}
+
+		*k = legacyKinds[value]
+		return nil
+	}
+
+	var name string
+
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	*k = Messagekind(name)
+	return nil
+}
+
+// legacyMessage is how Message is sent with the legacy protocol. UserId
+// replaces the one of Message, so spectators get the alias of a bidder in
+// Bidder instead of their id.
+type legacyMessage struct {
+	Message
+	Kind   int        `json:"kind"`
+	UserId *uuid.UUID `json:"user_id,omitempty"`
+	Bidder string     `json:"bidder,omitempty"`
+}
+
+// encodeLegacy turns a room message into what the client of userId receives
+// on the legacy protocol.
+func encodeLegacy(message Message, productId, userId uuid.UUID, spectator bool) legacyMessage {
+	legacy := legacyMessage{Message: message, Kind: message.Kind.legacyValue()}
+
+	if !spectator {
+		legacy.UserId = &message.UserId
+		return legacy
+	}
+
+	switch message.Kind {
+	case NewBidPlaced, AuctionFinished:
+		if message.UserId != uuid.Nil {
+			legacy.Bidder = BidderAlias(productId, message.UserId)
+		}
+	default:
+		if message.UserId == userId {
+			legacy.UserId = &message.UserId
+		}
+	}
+
+	return legacy
+}
+
+// Envelope wraps every event of the versioned protocol. Payload is one of
+// the payload types below, depending on Type.
+type Envelope struct {
+	Type    Messagekind `json:"type"`
+	Version int         `json:"version"`
+	Seq     int64       `json:"seq,omitempty"`
+	Ts      time.Time   `json:"ts"`
+	Payload any         `json:"payload"`
+}
+
+type PlaceBidPayload struct {
+	Amount *money.Money `json:"amount,omitempty"`
+	// MaxAmount turns the request into a proxy bid.
+	MaxAmount *money.Money `json:"max_amount,omitempty"`
+}
+
+// EmptyPayload is the payload of requests that need nothing but their type.
+type EmptyPayload struct{}
+
+type BidPlacedPayload struct {
+	Message        string       `json:"message"`
+	Amount         *money.Money `json:"amount,omitempty"`
+	MaxAmount      *money.Money `json:"max_amount,omitempty"`
+	ReserveMet     *bool        `json:"reserve_met,omitempty"`
+	MinimumNextBid *money.Money `json:"minimum_next_bid,omitempty"`
+}
+
+type PurchasePayload struct {
+	Message string      `json:"message"`
+	Price   money.Money `json:"price"`
+}
+
+type FailurePayload struct {
+	Reason         string       `json:"reason"`
+	MinimumNextBid *money.Money `json:"minimum_next_bid,omitempty"`
+}
+
+type NewBidPayload struct {
+	// Bidder is an alias that tells bidders apart without revealing them.
+	Bidder         string       `json:"bidder"`
+	IsYou          bool         `json:"is_you"`
+	Amount         money.Money  `json:"amount"`
+	ReserveMet     *bool        `json:"reserve_met,omitempty"`
+	MinimumNextBid *money.Money `json:"minimum_next_bid,omitempty"`
+}
+
+type AuctionExtendedPayload struct {
+	AuctionEnd time.Time `json:"auction_end"`
+}
+
+type PriceDroppedPayload struct {
+	AskingPrice money.Money `json:"asking_price"`
+}
+
+// AuctionFinishedPayload is the authoritative outcome of the auction:
+// clients should stop their countdown when it arrives rather than when
+// their clock reaches auction_end.
+type AuctionFinishedPayload struct {
+	Message     string       `json:"message"`
+	Sold        bool         `json:"sold"`
+	Winner      string       `json:"winner,omitempty"`
+	IsWinner    bool         `json:"is_winner"`
+	HammerPrice *money.Money `json:"hammer_price,omitempty"`
+	EndedAt     time.Time    `json:"ended_at"`
+	ServerTime  time.Time    `json:"server_time"`
+}
+
+// ClockPayload is the room's clock. TimeLeftMs is auction_end minus
+// server_time, so clients can count down without trusting their own clock.
+type ClockPayload struct {
+	ServerTime time.Time `json:"server_time"`
+	AuctionEnd time.Time `json:"auction_end"`
+	TimeLeftMs int64     `json:"time_left_ms"`
+}
+
+type ClosingPhasePayload struct {
+	// Phase is closing_soon, going_once or going_twice, the last phase
+	// before the auction closes.
+	Phase      string    `json:"phase"`
+	Message    string    `json:"message"`
+	ServerTime time.Time `json:"server_time"`
+	AuctionEnd time.Time `json:"auction_end"`
+	TimeLeftMs int64     `json:"time_left_ms"`
+}
+
+type ServerRestartingPayload struct {
+	Message string `json:"message"`
+	// ReconnectAfterMs is how long to wait before reconnecting; the
+	// connection is then closed with code 1012.
+	ReconnectAfterMs int64 `json:"reconnect_after_ms"`
+}
+
+// AuctionUpdatedPayload tells clients to fetch the product again, since the
+// seller edited it.
+type AuctionUpdatedPayload struct {
+	Message    string    `json:"message"`
+	AuctionEnd time.Time `json:"auction_end"`
+}
+
+type AuctionCancelledPayload struct {
+	Message string `json:"message"`
+}
+
+// ProtocolEvent describes an event type of the versioned protocol.
+type ProtocolEvent struct {
+	Type        Messagekind
+	FromClient  bool
+	Payload     any
+	Description string
+}
+
+// ProtocolEvents lists every event of the versioned protocol.
+var ProtocolEvents = []ProtocolEvent{
+	{PlaceBid, true, PlaceBidPayload{}, "Place a bid, or a proxy bid when max_amount is set."},
+	{BuyNow, true, EmptyPayload{}, "Buy the product at its buy-it-now price."},
+	{AcceptPrice, true, EmptyPayload{}, "Buy a dutch auction product at its current asking price."},
+	{SuccessfullyPlacedBid, false, BidPlacedPayload{}, "Your bid was placed."},
+	{SuccessfullyBoughtNow, false, PurchasePayload{}, "You bought the product at its buy-it-now price."},
+	{SuccessfullyAcceptedPrice, false, PurchasePayload{}, "You bought the product at its asking price."},
+	{FailedToPlaceBid, false, FailurePayload{}, "Your bid was rejected."},
+	{FailedToBuyNow, false, FailurePayload{}, "Your buy-it-now request was rejected."},
+	{FailedToAcceptPrice, false, FailurePayload{}, "Your accept-price request was rejected."},
+	{InvalidJSON, false, FailurePayload{}, "Your last message could not be read."},
+	{NewBidPlaced, false, NewBidPayload{}, "A bid was placed on the auction."},
+	{AuctionExtended, false, AuctionExtendedPayload{}, "A late bid extended the auction."},
+	{PriceDropped, false, PriceDroppedPayload{}, "The asking price of a dutch auction dropped."},
+	{AuctionFinished, false, AuctionFinishedPayload{}, "The auction is over; no more events follow."},
+	{RoomSnapshot, false, AuctionSnapshot{}, "The state of the auction, sent when joining."},
+	{TimeSync, false, ClockPayload{}, "The server clock, sent periodically to keep countdowns in step."},
+	{ClosingPhase, false, ClosingPhasePayload{}, "The auction entered one of its closing phases."},
+	{ServerRestarting, false, ServerRestartingPayload{}, "The server is restarting; reconnect with since to resume."},
+	{AuctionUpdated, false, AuctionUpdatedPayload{}, "The seller edited the auction."},
+	{AuctionCancelled, false, AuctionCancelledPayload{}, "The seller cancelled the auction; no more events follow."},
+}
+
+var ErrUnsupportedMessageType = errors.New("unsupported message type")
+
+// encodeEnvelope turns a room message into the event the client of userId
+// receives on the versioned protocol.
+func encodeEnvelope(message Message, productId, userId uuid.UUID) Envelope {
+	envelope := Envelope{
+		Type:    message.Kind,
+		Version: ProtocolVersion,
+		Seq:     message.Seq,
+		Ts:      time.Now(),
+	}
+
+	switch message.Kind {
+	case SuccessfullyPlacedBid:
+		envelope.Payload = BidPlacedPayload{
+			Message:        message.Message,
+			Amount:         message.Amount,
+			MaxAmount:      message.MaxAmount,
+			ReserveMet:     message.ReserveMet,
+			MinimumNextBid: message.MinimumNextBid,
+		}
+	case SuccessfullyBoughtNow, SuccessfullyAcceptedPrice:
+		payload := PurchasePayload{Message: message.Message}
+		if message.Amount != nil {
+			payload.Price = *message.Amount
+		}
+		envelope.Payload = payload
+	case FailedToPlaceBid, FailedToBuyNow, FailedToAcceptPrice, InvalidJSON:
+		envelope.Payload = FailurePayload{
+			Reason:         message.Message,
+			MinimumNextBid: message.MinimumNextBid,
+		}
+	case NewBidPlaced:
+		payload := NewBidPayload{
+			Bidder:         BidderAlias(productId, message.UserId),
+			IsYou:          message.UserId == userId,
+			ReserveMet:     message.ReserveMet,
+			MinimumNextBid: message.MinimumNextBid,
+		}
+		if message.Amount != nil {
+			payload.Amount = *message.Amount
+		}
+		envelope.Payload = payload
+	case AuctionExtended:
+		payload := AuctionExtendedPayload{}
+		if message.AuctionEnd != nil {
+			payload.AuctionEnd = *message.AuctionEnd
+		}
+		envelope.Payload = payload
+	case PriceDropped:
+		payload := PriceDroppedPayload{}
+		if message.Amount != nil {
+			payload.AskingPrice = *message.Amount
+		}
+		envelope.Payload = payload
+	case AuctionFinished:
+		clock := clockPayload(message)
+		payload := AuctionFinishedPayload{
+			Message:     message.Message,
+			HammerPrice: message.Amount,
+			EndedAt:     clock.AuctionEnd,
+			ServerTime:  clock.ServerTime,
+		}
+		if message.UserId != uuid.Nil {
+			payload.Sold = true
+			payload.Winner = BidderAlias(productId, message.UserId)
+			payload.IsWinner = message.UserId == userId
+		}
+		envelope.Payload = payload
+	case TimeSync:
+		envelope.Payload = clockPayload(message)
+	case ClosingPhase:
+		clock := clockPayload(message)
+		envelope.Payload = ClosingPhasePayload{
+			Phase:      message.Phase,
+			Message:    message.Message,
+			ServerTime: clock.ServerTime,
+			AuctionEnd: clock.AuctionEnd,
+			TimeLeftMs: clock.TimeLeftMs,
+		}
+	case RoomSnapshot:
+		envelope.Payload = message.Snapshot
+	case AuctionUpdated:
+		payload := AuctionUpdatedPayload{Message: message.Message}
+		if message.AuctionEnd != nil {
+			payload.AuctionEnd = *message.AuctionEnd
+		}
+		envelope.Payload = payload
+	case AuctionCancelled:
+		envelope.Payload = AuctionCancelledPayload{Message: message.Message}
+	case ServerRestarting:
+		envelope.Payload = ServerRestartingPayload{
+			Message:          message.Message,
+			ReconnectAfterMs: message.ReconnectAfterMs,
+		}
+	default:
+		envelope.Payload = EmptyPayload{}
+	}
+
+	return envelope
+}
+
+func clockPayload(message Message) ClockPayload {
+	var clock ClockPayload
+
+	if message.ServerTime != nil {
+		clock.ServerTime = *message.ServerTime
+	}
+
+	if message.AuctionEnd != nil {
+		clock.AuctionEnd = *message.AuctionEnd
+	}
+
+	if message.ServerTime != nil && message.AuctionEnd != nil {
+		clock.TimeLeftMs = max(clock.AuctionEnd.Sub(clock.ServerTime).Milliseconds(), 0)
+	}
+
+	return clock
+}
+
+// decodeRequest reads a request sent on the versioned protocol.
+func decodeRequest(data []byte) (Message, error) {
+	var envelope struct {
+		Type    Messagekind     `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Message{}, err
+	}
+
+	switch envelope.Type {
+	case PlaceBid:
+		var payload PlaceBidPayload
+
+		if len(envelope.Payload) > 0 {
+			if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+				return Message{}, err
+			}
+		}
+
+		return Message{Kind: PlaceBid, Amount: payload.Amount, MaxAmount: payload.MaxAmount}, nil
+	case BuyNow, AcceptPrice:
+		return Message{Kind: envelope.Type}, nil
+	default:
+		return Message{}, fmt.Errorf("%w: %q", ErrUnsupportedMessageType, envelope.Type)
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+)
+
+// ProtocolSchema is the JSON Schema of the versioned websocket protocol,
+// generated from the envelope and payload types.
+func ProtocolSchema() map[string]any {
+	builder := schemaBuilder{defs: make(map[string]any)}
+
+	var events []any
+
+	for _, event := range ProtocolEvents {
+		direction := "server to client"
+		if event.FromClient {
+			direction = "client to server"
+		}
+
+		events = append(events, map[string]any{
+			"title":       string(event.Type),
+			"description": event.Description + " Sent " + direction + ".",
+			"type":        "object",
+			"properties": map[string]any{
+				"type":    map[string]any{"const": string(event.Type)},
+				"version": map[string]any{"const": ProtocolVersion},
+				"seq":     map[string]any{"type": "integer", "minimum": 1},
+				"ts":      map[string]any{"type": "string", "format": "date-time"},
+				"payload": builder.schemaFor(reflect.TypeOf(event.Payload)),
+			},
+			"required": []string{"type", "payload"},
+		})
+	}
+
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "gobid websocket protocol, subprotocol " + ProtocolV2,
+		"oneOf":   events,
+		"$defs":   builder.defs,
+	}
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	uuidType        = reflect.TypeOf(uuid.UUID{})
+	moneyType       = reflect.TypeOf(money.Money{})
+	auctionTypeType = reflect.TypeOf(pgstore.AuctionType(""))
+)
+
+type schemaBuilder struct {
+	defs map[string]any
+}
+
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case moneyType:
+		b.defs["Money"] = map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"amount":   map[string]any{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?$`},
+				"currency": map[string]any{"type": "string", "pattern": "^[A-Z]{3}$"},
+			},
+			"required": []string{"amount", "currency"},
+		}
+		return map[string]any{"$ref": "#/$defs/Money"}
+	case auctionTypeType:
+		return map[string]any{"enum": []pgstore.AuctionType{
+			pgstore.AuctionTypeEnglish,
+			pgstore.AuctionTypeSealedFirstPrice,
+			pgstore.AuctionTypeSealedSecondPrice,
+			pgstore.AuctionTypeDutch,
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if _, ok := b.defs[t.Name()]; !ok {
+			// Registered before the fields so recursive types terminate.
+			b.defs[t.Name()] = nil
+			b.defs[t.Name()] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema describes a struct the way encoding/json writes it. Fields
+// that are pointers or omitempty are optional.
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schemaFor(field.Type)
+
+		if field.Type.Kind() != reflect.Pointer && !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
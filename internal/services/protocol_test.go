@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEncodeLegacy(t *testing.T) {
+	productId := uuid.New()
+	bidderId := uuid.New()
+	spectatorId := uuid.New()
+
+	tests := []struct {
+		name       string
+		message    Message
+		userId     uuid.UUID
+		spectator  bool
+		wantUserId string
+		wantBidder string
+	}{
+		{
+			name:       "bidder sees user id of new bid",
+			message:    Message{Kind: NewBidPlaced, UserId: bidderId},
+			userId:     uuid.New(),
+			wantUserId: bidderId.String(),
+		},
+		{
+			name:       "spectator sees alias of new bid",
+			message:    Message{Kind: NewBidPlaced, UserId: bidderId},
+			userId:     spectatorId,
+			spectator:  true,
+			wantBidder: BidderAlias(productId, bidderId),
+		},
+		{
+			name:       "spectator sees alias of winner",
+			message:    Message{Kind: AuctionFinished, UserId: bidderId},
+			userId:     spectatorId,
+			spectator:  true,
+			wantBidder: BidderAlias(productId, bidderId),
+		},
+		{
+			name:      "spectator sees no winner of unsold auction",
+			message:   Message{Kind: AuctionFinished},
+			userId:    spectatorId,
+			spectator: true,
+		},
+		{
+			name:       "spectator sees its own id on replies",
+			message:    Message{Kind: FailedToPlaceBid, UserId: spectatorId},
+			userId:     spectatorId,
+			spectator:  true,
+			wantUserId: spectatorId.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(encodeLegacy(tt.message, productId, tt.userId, tt.spectator))
+
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			var got struct {
+				UserId string `json:"user_id"`
+				Bidder string `json:"bidder"`
+			}
+
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshal %s: %v", data, err)
+			}
+
+			if got.UserId != tt.wantUserId {
+				t.Errorf("user_id = %q, want %q", got.UserId, tt.wantUserId)
+			}
+
+			if got.Bidder != tt.wantBidder {
+				t.Errorf("bidder = %q, want %q", got.Bidder, tt.wantBidder)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DefaultClosingPhases are announced when no others are configured.
+var DefaultClosingPhases = []time.Duration{5 * time.Minute, time.Minute, 10 * time.Second}
+
+// Closing phases, the last one announced last. Earlier phases are all
+// announced as ClosingSoon.
+const (
+	ClosingSoon = "closing_soon"
+	GoingOnce   = "going_once"
+	GoingTwice  = "going_twice"
+)
+
+// sorted copies the options with the closing phases longest first, without
+// duplicates or phases that are not positive.
+func (o RoomOptions) sorted() RoomOptions {
+	var phases []time.Duration
+	for _, phase := range o.ClosingPhases {
+		if phase > 0 {
+			phases = append(phases, phase)
+		}
+	}
+
+	slices.Sort(phases)
+	slices.Reverse(phases)
+	o.ClosingPhases = slices.Compact(phases)
+
+	return o
+}
+
+// phaseName names the closing phase at index i of the sorted phases.
+func (o RoomOptions) phaseName(i int) string {
+	switch len(o.ClosingPhases) - i {
+	case 1:
+		return GoingTwice
+	case 2:
+		return GoingOnce
+	default:
+		return ClosingSoon
+	}
+}
+
+// scheduleClosingPhase arms phaseTimer for the next closing phase still
+// ahead. Phases that passed while no room was running are not announced;
+// when the auction is extended the ones it moved back ahead are announced
+// again.
+func (r *AuctionRoom) scheduleClosingPhase() {
+	r.phaseTimer.Stop()
+
+	left := time.Until(r.AuctionEnd)
+
+	for i, phase := range r.options.ClosingPhases {
+		if phase < left {
+			r.nextPhase = i
+			r.phaseTimer.Reset(left - phase)
+			return
+		}
+	}
+}
+
+func (r *AuctionRoom) announceClosingPhase() {
+	phase := r.options.ClosingPhases[r.nextPhase]
+	auctionEnd, now := r.AuctionEnd, time.Now()
+
+	message := Message{
+		Kind:       ClosingPhase,
+		Phase:      r.options.phaseName(r.nextPhase),
+		Message:    fmt.Sprintf("%s left in the auction", phase),
+		AuctionEnd: &auctionEnd,
+		ServerTime: &now,
+	}
+
+	r.forEachClient(func(client *Client) {
+		r.send(client, message)
+	})
+
+	r.scheduleClosingPhase()
+}
+
+// syncTime sends the room's clock to every client, so they all count down
+// to the same end whatever their local clock says.
+func (r *AuctionRoom) syncTime() {
+	auctionEnd, now := r.AuctionEnd, time.Now()
+
+	r.forEachClient(func(client *Client) {
+		r.send(client, Message{
+			Kind:       TimeSync,
+			AuctionEnd: &auctionEnd,
+			ServerTime: &now,
+		})
+	})
+}
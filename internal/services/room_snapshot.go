@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// AuctionSnapshot is the state of an auction sent to a client in a
+// RoomSnapshot message when it joins its room. Bidders are only identified by
+// an alias that is stable within the auction.
+type AuctionSnapshot struct {
+	ProductId   uuid.UUID           `json:"product_id"`
+	ProductName string              `json:"product_name"`
+	Description string              `json:"description"`
+	AuctionType pgstore.AuctionType `json:"auction_type"`
+	Baseprice   money.Money         `json:"baseprice"`
+	// HighestBid, HighestBidder and MinimumNextBid are omitted for sealed
+	// auctions, whose bids stay hidden until they end.
+	HighestBid     *money.Money `json:"highest_bid,omitempty"`
+	HighestBidder  string       `json:"highest_bidder,omitempty"`
+	IsLeading      bool         `json:"is_leading"`
+	BidCount       int64        `json:"bid_count"`
+	BidderCount    int64        `json:"bidder_count"`
+	MinimumNextBid *money.Money `json:"minimum_next_bid,omitempty"`
+	ReserveMet     *bool        `json:"reserve_met,omitempty"`
+	BuyNowPrice    *money.Money `json:"buy_now_price,omitempty"`
+	// AskingPrice is the current price of a dutch auction.
+	AskingPrice *money.Money `json:"asking_price,omitempty"`
+	AuctionEnd  time.Time    `json:"auction_end"`
+	ServerTime  time.Time    `json:"server_time"`
+	// LastSeq is the number of the latest event the snapshot includes.
+	LastSeq int64 `json:"last_seq"`
+}
+
+// AuctionSnapshot reads the current state of an auction as seen by viewer_id.
+func (bs *BidsService) AuctionSnapshot(ctx context.Context, product_id, viewer_id uuid.UUID) (AuctionSnapshot, error) {
+	product, err := bs.queries.GetProductById(ctx, product_id)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return AuctionSnapshot{}, ErrProductNotFound
+		}
+		return AuctionSnapshot{}, err
+	}
+
+	// The sequence is read first: events recorded meanwhile are sent live
+	// and at worst repeat what the snapshot already shows.
+	lastSeq, err := bs.queries.GetLastAuctionEventSeq(ctx, product_id)
+
+	if err != nil {
+		return AuctionSnapshot{}, err
+	}
+
+	stats, err := bs.queries.GetBidStatsByProductId(ctx, product_id)
+
+	if err != nil {
+		return AuctionSnapshot{}, err
+	}
+
+	now := time.Now()
+
+	snapshot := AuctionSnapshot{
+		ProductId:   product.ID,
+		ProductName: product.ProductName,
+		Description: product.Description,
+		AuctionType: product.AuctionType,
+		Baseprice:   money.New(product.Baseprice, product.Currency),
+		BidCount:    stats.BidCount,
+		BidderCount: stats.BidderCount,
+		AuctionEnd:  product.AuctionEnd,
+		ServerTime:  now,
+		LastSeq:     lastSeq,
+	}
+
+	if product.BuyNowPrice != nil {
+		buyNowPrice := money.New(*product.BuyNowPrice, product.Currency)
+		snapshot.BuyNowPrice = &buyNowPrice
+	}
+
+	if schedule, ok := NewDutchSchedule(product); ok {
+		askingPrice := money.New(schedule.PriceAt(now), product.Currency)
+		snapshot.AskingPrice = &askingPrice
+		return snapshot, nil
+	}
+
+	if isSealed(product.AuctionType) {
+		return snapshot, nil
+	}
+
+	var highestBid *pgstore.Bid
+
+	bid, err := bs.queries.GetHighestBidByProductId(ctx, product_id)
+
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return AuctionSnapshot{}, err
+		}
+	} else {
+		highestBid = &bid
+	}
+
+	minimumNextBid := bs.minimumNextBid(product, highestBid)
+	snapshot.MinimumNextBid = &minimumNextBid
+
+	if highestBid != nil {
+		amount := money.New(highestBid.BidAmount, product.Currency)
+		snapshot.HighestBid = &amount
+		snapshot.HighestBidder = BidderAlias(product_id, highestBid.BidderID)
+		snapshot.IsLeading = highestBid.BidderID == viewer_id
+	}
+
+	if product.ReservePrice != nil {
+		reserveMet := highestBid != nil && highestBid.BidAmount >= *product.ReservePrice
+		snapshot.ReserveMet = &reserveMet
+	}
+
+	return snapshot, nil
+}
+
+// BidderAlias hides who a bidder is while letting everyone in the auction
+// tell bidders apart. The same bidder gets a different alias in each
+// auction.
+func BidderAlias(product_id, bidder_id uuid.UUID) string {
+	sum := sha256.Sum256(append(product_id[:], bidder_id[:]...))
+	return "bidder-" + hex.EncodeToString(sum[:4])
+}
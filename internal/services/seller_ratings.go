@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrNotRateable  = errors.New("only the winner of a sold auction can rate its seller")
+	ErrAlreadyRated = errors.New("seller has already been rated for this auction")
+)
+
+// RateSeller records how the winner of a sold auction rates its seller,
+// from 1 to 5. Each auction is rated once.
+func (us *UserService) RateSeller(ctx context.Context, productId, buyerId uuid.UUID, score int16, comment string) (pgstore.SellerRating, error) {
+	result, err := us.queries.GetAuctionResultByProductId(ctx, productId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.SellerRating{}, ErrNotRateable
+		}
+		return pgstore.SellerRating{}, err
+	}
+
+	if !result.IsSold || result.WinnerID.UUID != buyerId {
+		return pgstore.SellerRating{}, ErrNotRateable
+	}
+
+	product, err := us.queries.GetProductById(ctx, productId)
+
+	if err != nil {
+		return pgstore.SellerRating{}, err
+	}
+
+	rating, err := us.queries.CreateSellerRating(ctx, pgstore.CreateSellerRatingParams{
+		ProductID: productId,
+		SellerID:  product.SellerID,
+		BuyerID:   buyerId,
+		Score:     score,
+		Comment:   comment,
+	})
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return pgstore.SellerRating{}, ErrAlreadyRated
+		}
+
+		return pgstore.SellerRating{}, err
+	}
+
+	return rating, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SettlementService struct {
+	pool    *pgxpool.Pool
+	queries *pgstore.Queries
+}
+
+func NewSettlementService(pool *pgxpool.Pool) SettlementService {
+	return SettlementService{
+		pool:    pool,
+		queries: pgstore.New(pool),
+	}
+}
+
+var ErrAuctionNotEnded = errors.New("auction has not ended yet")
+
+// AuctionNotEndedError is returned when settling an auction that is still
+// running, e.g. because a late bid extended it. It matches ErrAuctionNotEnded.
+type AuctionNotEndedError struct {
+	AuctionEnd time.Time
+}
+
+func (e *AuctionNotEndedError) Error() string {
+	return fmt.Sprintf("%s, it ends at %s", ErrAuctionNotEnded, e.AuctionEnd.Format(time.RFC3339))
+}
+
+func (e *AuctionNotEndedError) Is(target error) bool {
+	return target == ErrAuctionNotEnded
+}
+
+// SettleAuction records the outcome of an ended auction and marks the product
+// as sold when its highest bid meets the reserve price, if any. Settling an
+// auction twice returns the result stored by the first call.
+func (ss *SettlementService) SettleAuction(ctx context.Context, productId uuid.UUID) (pgstore.AuctionResult, error) {
+	tx, err := ss.pool.Begin(ctx)
+
+	if err != nil {
+		return pgstore.AuctionResult{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := ss.queries.WithTx(tx)
+
+	product, err := queries.GetProductByIdForUpdate(ctx, productId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.AuctionResult{}, ErrProductNotFound
+		}
+		return pgstore.AuctionResult{}, err
+	}
+
+	if product.CancelledAt.Valid {
+		return pgstore.AuctionResult{}, ErrAuctionCancelled
+	}
+
+	result, err := queries.GetAuctionResultByProductId(ctx, productId)
+
+	if err == nil {
+		return result, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return pgstore.AuctionResult{}, err
+	}
+
+	if product.AuctionEnd.After(time.Now()) {
+		return pgstore.AuctionResult{}, &AuctionNotEndedError{AuctionEnd: product.AuctionEnd}
+	}
+
+	params := pgstore.CreateAuctionResultParams{
+		ProductID: productId,
+		EndedAt:   product.AuctionEnd,
+		Currency:  product.Currency,
+	}
+
+	bids, err := queries.GetTopBidsByProductId(ctx, pgstore.GetTopBidsByProductIdParams{
+		ProductID: productId,
+		Limit:     2,
+	})
+
+	if err != nil {
+		return pgstore.AuctionResult{}, err
+	}
+
+	if len(bids) > 0 && (product.ReservePrice == nil || bids[0].BidAmount >= *product.ReservePrice) {
+		hammerPrice := clearingPrice(product, bids)
+
+		params.WinnerID = uuid.NullUUID{UUID: bids[0].BidderID, Valid: true}
+		params.WinningBidID = uuid.NullUUID{UUID: bids[0].ID, Valid: true}
+		params.HammerPrice = &hammerPrice
+		params.IsSold = true
+	}
+
+	result, err = queries.CreateAuctionResult(ctx, params)
+
+	if err != nil {
+		return pgstore.AuctionResult{}, err
+	}
+
+	err = queries.SetProductSold(ctx, pgstore.SetProductSoldParams{
+		ID:     productId,
+		IsSold: result.IsSold,
+	})
+
+	if err != nil {
+		return pgstore.AuctionResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return pgstore.AuctionResult{}, err
+	}
+
+	slog.Info("Auction settled", "auctionID", productId, "isSold", result.IsSold)
+
+	return result, nil
+}
+
+// SettleEndedAuctions settles every auction that ended without a recorded
+// result, e.g. while the server was down. An auction that fails to settle
+// does not keep the others from settling; their errors are joined.
+func (ss *SettlementService) SettleEndedAuctions(ctx context.Context) error {
+	products, err := ss.queries.ListUnsettledEndedAuctions(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, product := range products {
+		if _, err := ss.SettleAuction(ctx, product.ID); err != nil {
+			slog.Error("Failed to settle ended auction", "auctionID", product.ID, "error", err)
+			errs = append(errs, fmt.Errorf("settle auction %s: %w", product.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// clearingPrice is what the winner of an auction pays given its highest bids,
+// best first. It is the winning bid itself except in second-price auctions,
+// where it is the runner-up's bid (or the base price when nobody else bid)
+// raised to the reserve price, if any.
+func clearingPrice(product pgstore.Product, bids []pgstore.Bid) money.Amount {
+	winningBid := bids[0]
+
+	if product.AuctionType != pgstore.AuctionTypeSealedSecondPrice {
+		return winningBid.BidAmount
+	}
+
+	price := product.Baseprice
+
+	if len(bids) > 1 {
+		price = bids[1].BidAmount
+	}
+
+	if product.ReservePrice != nil {
+		price = max(price, *product.ReservePrice)
+	}
+
+	return min(price, winningBid.BidAmount)
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+)
+
+func TestClearingPrice(t *testing.T) {
+	reserve := money.Amount(400)
+
+	bids := func(amounts ...money.Amount) []pgstore.Bid {
+		var bids []pgstore.Bid
+		for _, amount := range amounts {
+			bids = append(bids, pgstore.Bid{BidAmount: amount})
+		}
+		return bids
+	}
+
+	tests := []struct {
+		name    string
+		product pgstore.Product
+		bids    []pgstore.Bid
+		want    money.Amount
+	}{
+		{"english pays the winning bid", pgstore.Product{AuctionType: pgstore.AuctionTypeEnglish, Baseprice: 100}, bids(500, 300), 500},
+		{"first price pays the winning bid", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedFirstPrice, Baseprice: 100}, bids(500, 300), 500},
+		{"dutch pays the accepted price", pgstore.Product{AuctionType: pgstore.AuctionTypeDutch, Baseprice: 1000}, bids(700), 700},
+		{"second price pays the runner-up", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedSecondPrice, Baseprice: 100}, bids(500, 300), 300},
+		{"second price with equal bids", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedSecondPrice, Baseprice: 100}, bids(500, 500), 500},
+		{"single sealed bid pays baseprice", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedSecondPrice, Baseprice: 100}, bids(500), 100},
+		{"second price raised to the reserve", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedSecondPrice, Baseprice: 100, ReservePrice: &reserve}, bids(500, 300), 400},
+		{"single sealed bid raised to the reserve", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedSecondPrice, Baseprice: 100, ReservePrice: &reserve}, bids(450), 400},
+		{"runner-up above the reserve", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedSecondPrice, Baseprice: 100, ReservePrice: &reserve}, bids(500, 450), 450},
+		{"never more than the winning bid", pgstore.Product{AuctionType: pgstore.AuctionTypeSealedSecondPrice, Baseprice: 100, ReservePrice: &reserve}, bids(400), 400},
+	}
+
+	for _, tt := range tests {
+		if got := clearingPrice(tt.product, tt.bids); got != tt.want {
+			t.Errorf("%s: clearingPrice() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Clients told that the server is restarting reconnect after a random delay
+// of at least minReconnectDelay, so they do not all come back at once.
+const (
+	minReconnectDelay = time.Second
+	reconnectJitter   = 4 * time.Second
+)
+
+// Shutdown stops the room for a server restart without settling the
+// auction: its clients are told to reconnect, which the restarted server or
+// another instance picks up. It returns right away; the room is done once
+// Finished is closed.
+func (r *AuctionRoom) Shutdown() {
+	r.shutdownOnce.Do(func() {
+		r.restarting.Store(true)
+		close(r.shutdown)
+	})
+}
+
+// Restarting reports whether the room was stopped by Shutdown.
+func (r *AuctionRoom) Restarting() bool {
+	return r.restarting.Load()
+}
+
+func (r *AuctionRoom) closeForRestart() {
+	slog.Info("Closing auction room for a server restart", "auctionID", r.Id)
+
+	r.forEachClient(func(client *Client) {
+		reconnectAfter := minReconnectDelay + rand.N(reconnectJitter)
+
+		r.send(client, Message{
+			Kind:             ServerRestarting,
+			Message:          "The server is restarting, reconnect to resume the auction",
+			ReconnectAfterMs: reconnectAfter.Milliseconds(),
+		})
+		r.closeClient(client, websocket.CloseServiceRestart, "server restarting")
+	})
+}
+
+// CloseRooms shuts down every room of the lobby for a server restart.
+func (l *AuctionLobby) CloseRooms() {
+	l.Lock()
+	defer l.Unlock()
+
+	l.ShuttingDown = true
+
+	for _, room := range l.Rooms {
+		l.ShutdownRoom(room)
+	}
+}
+
+// ShutdownRoom shuts down a room of the lobby for a server restart and
+// keeps it for Wait. The lobby must be locked.
+func (l *AuctionLobby) ShutdownRoom(room *AuctionRoom) {
+	room.Shutdown()
+	l.closed = append(l.closed, room)
+}
+
+// Wait blocks until the rooms shut down by CloseRooms, or started after it,
+// have finished, which includes any bid they were placing, and their
+// websocket clients have been sent a close frame, or until ctx is done.
+func (l *AuctionLobby) Wait(ctx context.Context) error {
+	l.Lock()
+	rooms := slices.Clone(l.closed)
+	l.Unlock()
+
+	for _, room := range rooms {
+		select {
+		case <-room.Finished:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		writersDone := make(chan struct{})
+		go func() {
+			room.writers.Wait()
+			close(writersDone)
+		}()
+
+		select {
+		case <-writersDone:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
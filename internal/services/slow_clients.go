@@ -0,0 +1,159 @@
+package services
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// slowClientTimeout is how long a client may stay behind before it is
+	// disconnected.
+	slowClientTimeout = 10 * time.Second
+	slowClientCheck   = time.Second
+)
+
+// RoomStats counts what a room gave up on to keep slow clients from holding
+// everyone else back.
+type RoomStats struct {
+	DroppedMessages     int64 `json:"dropped_messages"`
+	DisconnectedClients int64 `json:"disconnected_clients"`
+	Spectators          int64 `json:"spectators"`
+}
+
+type roomCounters struct {
+	droppedMessages     atomic.Int64
+	disconnectedClients atomic.Int64
+	spectators          atomic.Int64
+}
+
+// Stats can be read from any goroutine.
+func (r *AuctionRoom) Stats() RoomStats {
+	return RoomStats{
+		DroppedMessages:     r.counters.droppedMessages.Load(),
+		DisconnectedClients: r.counters.disconnectedClients.Load(),
+		Spectators:          r.counters.spectators.Load(),
+	}
+}
+
+// coalescible events only matter in their latest version, so a client that
+// is behind can skip the older ones.
+func (k Messagekind) coalescible() bool {
+	switch k {
+	case PriceDropped, AuctionExtended, TimeSync, ClosingPhase, AuctionUpdated:
+		return true
+	default:
+		return false
+	}
+}
+
+// send queues a message for a client without ever blocking the room. When
+// the client's buffer is full, coalescible messages wait for it to catch up,
+// replacing older ones of the same kind; any other message cannot be
+// skipped, so the client is disconnected and has to resume from its last
+// event.
+func (r *AuctionRoom) send(client *Client, message Message) {
+	if client.closed {
+		return
+	}
+
+	if client.joining {
+		client.held = append(client.held, message)
+		return
+	}
+
+	if r.flushPending(client) {
+		select {
+		case client.Send <- message:
+			return
+		default:
+		}
+	}
+
+	if client.laggingSince.IsZero() {
+		client.laggingSince = time.Now()
+	}
+
+	if !message.Kind.coalescible() {
+		r.counters.droppedMessages.Add(1)
+		r.disconnectSlowClient(client)
+		return
+	}
+
+	for i, pending := range client.pending {
+		if pending.Kind == message.Kind {
+			client.pending[i] = message
+			r.counters.droppedMessages.Add(1)
+			return
+		}
+	}
+
+	client.pending = append(client.pending, message)
+}
+
+// flushPending queues the messages held back for a client, oldest first. It
+// reports whether the client has caught up.
+func (r *AuctionRoom) flushPending(client *Client) bool {
+	for len(client.pending) > 0 {
+		select {
+		case client.Send <- client.pending[0]:
+			client.pending = client.pending[1:]
+		default:
+			return false
+		}
+	}
+
+	client.laggingSince = time.Time{}
+	return true
+}
+
+// checkSlowClients catches up clients with held back messages and
+// disconnects the ones that stayed behind for too long.
+func (r *AuctionRoom) checkSlowClients() {
+	r.forEachClient(func(client *Client) {
+		if len(client.pending) == 0 || r.flushPending(client) {
+			return
+		}
+
+		if time.Since(client.laggingSince) > slowClientTimeout {
+			r.disconnectSlowClient(client)
+		}
+	})
+}
+
+func (r *AuctionRoom) disconnectSlowClient(client *Client) {
+	slog.Info("Disconnecting slow client", "auctionID", r.Id, "Client", client)
+
+	r.counters.droppedMessages.Add(int64(len(client.pending)))
+	r.counters.disconnectedClients.Add(1)
+
+	r.removeClient(client)
+	r.closeClient(client, websocket.CloseTryAgainLater, "too slow to keep up with the auction, reconnect to resume")
+}
+
+// closeClient makes the client's writer close the connection with the given
+// code once it has sent what is already queued.
+func (r *AuctionRoom) closeClient(client *Client, code int, reason string) {
+	if client.closed {
+		return
+	}
+
+	// A client closed while joining still gets what it was sent meanwhile,
+	// such as the outcome of the auction.
+	if client.joining {
+		r.releaseHeld(client)
+
+		if client.closed {
+			return
+		}
+	}
+
+	client.closed = true
+	client.pending = nil
+	client.closeCode = code
+	client.closeReason = reason
+
+	close(client.Send)
+}
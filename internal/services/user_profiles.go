@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// emailTokenLifetime is how long a user has to verify a new email.
+const emailTokenLifetime = 24 * time.Hour
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrInvalidEmailToken = errors.New("invalid or expired email verification token")
+)
+
+// Account is what users see of their own account.
+type Account struct {
+	Id       uuid.UUID `json:"id"`
+	UserName string    `json:"user_name"`
+	Email    string    `json:"email"`
+	// PendingEmail replaces Email once it is verified.
+	PendingEmail string    `json:"pending_email,omitempty"`
+	Bio          string    `json:"bio"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AccountUpdate holds the fields a user changes; nil fields are kept.
+// Changing the email or the password requires CurrentPassword.
+type AccountUpdate struct {
+	UserName        *string
+	Bio             *string
+	Email           *string
+	NewPassword     *string
+	CurrentPassword string
+}
+
+// SellerRatingSummary is how the buyers of a seller rated them.
+type SellerRatingSummary struct {
+	// AverageScore goes from 1 to 5, or is 0 while there are no ratings.
+	AverageScore float64 `json:"average_score"`
+	RatingCount  int64   `json:"rating_count"`
+	SoldCount    int64   `json:"sold_count"`
+}
+
+// PublicProfile is what everyone may see of a user. It leaves out their
+// email.
+type PublicProfile struct {
+	UserName       string              `json:"user_name"`
+	Bio            string              `json:"bio"`
+	MemberSince    time.Time           `json:"member_since"`
+	Rating         SellerRatingSummary `json:"rating"`
+	ActiveListings []ProductListing    `json:"active_listings"`
+	// Id lets callers list the user's products; it is not shown.
+	Id uuid.UUID `json:"-"`
+}
+
+func (us *UserService) GetAccount(ctx context.Context, userId uuid.UUID) (Account, error) {
+	user, err := us.queries.GetUserById(ctx, userId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Account{}, ErrUserNotFound
+		}
+		return Account{}, err
+	}
+
+	return Account{
+		Id:           user.ID,
+		UserName:     user.UserName,
+		Email:        user.Email,
+		PendingEmail: user.PendingEmail.String,
+		Bio:          user.Bio,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+	}, nil
+}
+
+// UpdateAccount applies a user's changes to their account. A new email is
+// only used once verified: it is kept as pending and a token is mailed to
+// it, see VerifyEmail. A new password ends every session of the user.
+func (us *UserService) UpdateAccount(ctx context.Context, userId uuid.UUID, update AccountUpdate) (Account, error) {
+	tx, err := us.pool.Begin(ctx)
+
+	if err != nil {
+		return Account{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := us.queries.WithTx(tx)
+
+	user, err := queries.GetUserById(ctx, userId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Account{}, ErrUserNotFound
+		}
+		return Account{}, err
+	}
+
+	if update.Email != nil || update.NewPassword != nil {
+		err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(update.CurrentPassword))
+
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return Account{}, ErrInvalidCredentials
+			}
+			return Account{}, err
+		}
+	}
+
+	if update.UserName != nil || update.Bio != nil {
+		params := pgstore.UpdateUserProfileParams{
+			ID:       userId,
+			UserName: user.UserName,
+			Bio:      user.Bio,
+		}
+
+		if update.UserName != nil {
+			params.UserName = *update.UserName
+		}
+
+		if update.Bio != nil {
+			params.Bio = *update.Bio
+		}
+
+		if err := queries.UpdateUserProfile(ctx, params); err != nil {
+			var pgErr *pgconn.PgError
+
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return Account{}, ErrDuplicateEmailOrUsername
+			}
+
+			return Account{}, err
+		}
+	}
+
+	if update.NewPassword != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*update.NewPassword), bcrypt.DefaultCost)
+
+		if err != nil {
+			return Account{}, err
+		}
+
+		err = queries.UpdateUserPassword(ctx, pgstore.UpdateUserPasswordParams{
+			ID:           userId,
+			PasswordHash: hash,
+		})
+
+		if err != nil {
+			return Account{}, err
+		}
+
+		// Every session of the user ends; the caller gives the current one
+		// a new token, see TrackSession.
+		if err := queries.DeleteUserSessions(ctx, userId); err != nil {
+			return Account{}, err
+		}
+	}
+
+	var token string
+
+	if update.Email != nil && *update.Email != user.Email {
+		_, err := queries.GetUserByEmail(ctx, *update.Email)
+
+		if err == nil {
+			return Account{}, ErrDuplicateEmailOrUsername
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return Account{}, err
+		}
+
+		var tokenHash []byte
+		token, tokenHash, err = newEmailToken()
+
+		if err != nil {
+			return Account{}, err
+		}
+
+		err = queries.SetUserPendingEmail(ctx, pgstore.SetUserPendingEmailParams{
+			ID:                  userId,
+			PendingEmail:        pgtype.Text{String: *update.Email, Valid: true},
+			EmailTokenHash:      tokenHash,
+			EmailTokenExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(emailTokenLifetime), Valid: true},
+		})
+
+		if err != nil {
+			return Account{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Account{}, err
+	}
+
+	// The changes are saved by now, so a failed mail does not fail the
+	// update; the user can ask for another one by setting the email again.
+	if token != "" {
+		if err := us.mailer.SendEmailVerification(ctx, *update.Email, token); err != nil {
+			slog.Error("Failed to send email verification", "userID", userId, "error", err)
+		}
+	}
+
+	return us.GetAccount(ctx, userId)
+}
+
+// VerifyEmail replaces the email of a user with their pending one, given
+// the token that was mailed to it.
+func (us *UserService) VerifyEmail(ctx context.Context, userId uuid.UUID, token string) (Account, error) {
+	_, err := us.queries.ConfirmUserPendingEmail(ctx, pgstore.ConfirmUserPendingEmailParams{
+		ID:             userId,
+		EmailTokenHash: hashEmailToken(token),
+	})
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return Account{}, ErrInvalidEmailToken
+		case errors.As(err, &pgErr) && pgErr.Code == "23505":
+			return Account{}, ErrDuplicateEmailOrUsername
+		default:
+			return Account{}, err
+		}
+	}
+
+	return us.GetAccount(ctx, userId)
+}
+
+// GetPublicProfile reads the profile of a user by their username, with how
+// their buyers rated them. ActiveListings is left for the caller to fill.
+func (us *UserService) GetPublicProfile(ctx context.Context, userName string) (PublicProfile, error) {
+	user, err := us.queries.GetUserByUserName(ctx, userName)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PublicProfile{}, ErrUserNotFound
+		}
+		return PublicProfile{}, err
+	}
+
+	summary, err := us.queries.GetSellerRatingSummary(ctx, user.ID)
+
+	if err != nil {
+		return PublicProfile{}, err
+	}
+
+	return PublicProfile{
+		Id:          user.ID,
+		UserName:    user.UserName,
+		Bio:         user.Bio,
+		MemberSince: user.CreatedAt,
+		Rating: SellerRatingSummary{
+			AverageScore: summary.AverageScore,
+			RatingCount:  summary.RatingCount,
+			SoldCount:    summary.SoldCount,
+		},
+	}, nil
+}
+
+// newEmailToken makes a random token to mail and the hash to store.
+func newEmailToken() (string, []byte, error) {
+	data := make([]byte, 32)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", nil, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(data)
+
+	return token, hashEmailToken(token), nil
+}
+
+func hashEmailToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"context"
+
+	"github.com/andresilvase/gobid/internal/store/pgstore"
+	"github.com/google/uuid"
+)
+
+// TrackSession records that the session with token belongs to userId, so
+// it ends along with the user's other sessions when their password changes.
+func (us *UserService) TrackSession(ctx context.Context, userId uuid.UUID, token string) error {
+	return us.queries.CreateUserSession(ctx, pgstore.CreateUserSessionParams{
+		Token:  token,
+		UserID: userId,
+	})
+}
+
+// ForgetSession stops tracking a session the user logged out of.
+func (us *UserService) ForgetSession(ctx context.Context, token string) error {
+	return us.queries.DeleteUserSession(ctx, token)
+}
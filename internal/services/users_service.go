@@ -18,12 +18,14 @@ var ErrInvalidCredentials = errors.New("invalid credentials")
 type UserService struct {
 	pool    *pgxpool.Pool
 	queries *pgstore.Queries
+	mailer  Mailer
 }
 
-func NewUserService(pool *pgxpool.Pool) UserService {
+func NewUserService(pool *pgxpool.Pool, mailer Mailer) UserService {
 	return UserService{
 		pool:    pool,
 		queries: pgstore.New(pool),
+		mailer:  mailer,
 	}
 }
 
@@ -7,8 +7,11 @@ package pgstore
 
 import (
 	"context"
+	"time"
 
+	"github.com/andresilvase/gobid/internal/money"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createBid = `-- name: CreateBid :one
@@ -19,9 +22,9 @@ RETURNING id, product_id, bidder_id, bid_amount, created_at
 `
 
 type CreateBidParams struct {
-	ProductID uuid.UUID `json:"product_id"`
-	BidderID  uuid.UUID `json:"bidder_id"`
-	BidAmount float64   `json:"bid_amount"`
+	ProductID uuid.UUID    `json:"product_id"`
+	BidderID  uuid.UUID    `json:"bidder_id"`
+	BidAmount money.Amount `json:"bid_amount"`
 }
 
 func (q *Queries) CreateBid(ctx context.Context, arg CreateBidParams) (Bid, error) {
@@ -37,6 +40,21 @@ func (q *Queries) CreateBid(ctx context.Context, arg CreateBidParams) (Bid, erro
 	return i, err
 }
 
+const deleteBidsByProductIdAndBidderId = `-- name: DeleteBidsByProductIdAndBidderId :exec
+DELETE FROM bids
+WHERE product_id = $1 AND bidder_id = $2
+`
+
+type DeleteBidsByProductIdAndBidderIdParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	BidderID  uuid.UUID `json:"bidder_id"`
+}
+
+func (q *Queries) DeleteBidsByProductIdAndBidderId(ctx context.Context, arg DeleteBidsByProductIdAndBidderIdParams) error {
+	_, err := q.db.Exec(ctx, deleteBidsByProductIdAndBidderId, arg.ProductID, arg.BidderID)
+	return err
+}
+
 const getBidByProductId = `-- name: GetBidByProductId :many
 SELECT id, product_id, bidder_id, bid_amount, created_at FROM bids
 WHERE product_id = $1
@@ -69,10 +87,28 @@ func (q *Queries) GetBidByProductId(ctx context.Context, productID uuid.UUID) ([
 	return items, nil
 }
 
+const getBidStatsByProductId = `-- name: GetBidStatsByProductId :one
+SELECT COUNT(*) AS bid_count, COUNT(DISTINCT bidder_id) AS bidder_count
+FROM bids
+WHERE product_id = $1
+`
+
+type GetBidStatsByProductIdRow struct {
+	BidCount    int64 `json:"bid_count"`
+	BidderCount int64 `json:"bidder_count"`
+}
+
+func (q *Queries) GetBidStatsByProductId(ctx context.Context, productID uuid.UUID) (GetBidStatsByProductIdRow, error) {
+	row := q.db.QueryRow(ctx, getBidStatsByProductId, productID)
+	var i GetBidStatsByProductIdRow
+	err := row.Scan(&i.BidCount, &i.BidderCount)
+	return i, err
+}
+
 const getHighestBidByProductId = `-- name: GetHighestBidByProductId :one
 SELECT id, product_id, bidder_id, bid_amount, created_at FROM bids
 WHERE product_id = $1
-ORDER BY bid_amount DESC
+ORDER BY bid_amount DESC, created_at ASC
 LIMIT 1
 `
 
@@ -88,3 +124,171 @@ func (q *Queries) GetHighestBidByProductId(ctx context.Context, productID uuid.U
 	)
 	return i, err
 }
+
+const getTopBidsByProductId = `-- name: GetTopBidsByProductId :many
+SELECT id, product_id, bidder_id, bid_amount, created_at FROM bids
+WHERE product_id = $1
+ORDER BY bid_amount DESC, created_at ASC
+LIMIT $2
+`
+
+type GetTopBidsByProductIdParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Limit     int32     `json:"limit"`
+}
+
+func (q *Queries) GetTopBidsByProductId(ctx context.Context, arg GetTopBidsByProductIdParams) ([]Bid, error) {
+	rows, err := q.db.Query(ctx, getTopBidsByProductId, arg.ProductID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Bid
+	for rows.Next() {
+		var i Bid
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.BidderID,
+			&i.BidAmount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuctionsByBidderId = `-- name: ListAuctionsByBidderId :many
+SELECT
+    products.id, products.product_name, products.auction_type, products.currency,
+    products.auction_end, products.cancelled_at,
+    mine.highest_bid, mine.bid_count, mine.last_bid_at,
+    leader.bidder_id AS leader_id,
+    auction_results.product_id IS NOT NULL AS settled,
+    auction_results.winner_id
+FROM (
+    SELECT
+        product_id,
+        MAX(bid_amount)::BIGINT AS highest_bid,
+        COUNT(*) AS bid_count,
+        MAX(created_at)::TIMESTAMP AS last_bid_at
+    FROM bids
+    WHERE bidder_id = $1
+    GROUP BY product_id
+) AS mine
+JOIN products ON products.id = mine.product_id
+CROSS JOIN LATERAL (
+    SELECT bids.bidder_id FROM bids
+    WHERE bids.product_id = products.id
+    ORDER BY bids.bid_amount DESC, bids.created_at ASC
+    LIMIT 1
+) AS leader
+LEFT JOIN auction_results ON auction_results.product_id = products.id
+ORDER BY products.auction_end DESC, products.id
+`
+
+type ListAuctionsByBidderIdRow struct {
+	ID          uuid.UUID          `json:"id"`
+	ProductName string             `json:"product_name"`
+	AuctionType AuctionType        `json:"auction_type"`
+	Currency    string             `json:"currency"`
+	AuctionEnd  time.Time          `json:"auction_end"`
+	CancelledAt pgtype.Timestamptz `json:"cancelled_at"`
+	HighestBid  int64              `json:"highest_bid"`
+	BidCount    int64              `json:"bid_count"`
+	LastBidAt   pgtype.Timestamp   `json:"last_bid_at"`
+	LeaderID    uuid.UUID          `json:"leader_id"`
+	Settled     bool               `json:"settled"`
+	WinnerID    uuid.NullUUID      `json:"winner_id"`
+}
+
+// Every auction bidder_id bid on, with their highest bid, who leads it (the
+// highest bid, first placed on ties) and its result once settled.
+func (q *Queries) ListAuctionsByBidderId(ctx context.Context, bidderID uuid.UUID) ([]ListAuctionsByBidderIdRow, error) {
+	rows, err := q.db.Query(ctx, listAuctionsByBidderId, bidderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAuctionsByBidderIdRow
+	for rows.Next() {
+		var i ListAuctionsByBidderIdRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductName,
+			&i.AuctionType,
+			&i.Currency,
+			&i.AuctionEnd,
+			&i.CancelledAt,
+			&i.HighestBid,
+			&i.BidCount,
+			&i.LastBidAt,
+			&i.LeaderID,
+			&i.Settled,
+			&i.WinnerID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBidsByProductId = `-- name: ListBidsByProductId :many
+SELECT id, product_id, bidder_id, bid_amount, created_at FROM bids
+WHERE product_id = $1
+  AND (
+    $2::TIMESTAMP IS NULL
+    OR (created_at, id) < ($2, $3::UUID)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListBidsByProductIdParams struct {
+	ProductID       uuid.UUID        `json:"product_id"`
+	BeforeCreatedAt pgtype.Timestamp `json:"before_created_at"`
+	BeforeID        uuid.NullUUID    `json:"before_id"`
+	MaxBids         int32            `json:"max_bids"`
+}
+
+// Bids are listed newest first; (created_at, id) of the last bid of a page
+// is where the next one starts.
+func (q *Queries) ListBidsByProductId(ctx context.Context, arg ListBidsByProductIdParams) ([]Bid, error) {
+	rows, err := q.db.Query(ctx, listBidsByProductId,
+		arg.ProductID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.MaxBids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Bid
+	for rows.Next() {
+		var i Bid
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.BidderID,
+			&i.BidAmount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS auction_results (
+    product_id UUID PRIMARY KEY REFERENCES products(id),
+    winner_id UUID REFERENCES users(id),
+    winning_bid_id UUID REFERENCES bids(id),
+    hammer_price FLOAT,
+    is_sold BOOLEAN NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS auction_results;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
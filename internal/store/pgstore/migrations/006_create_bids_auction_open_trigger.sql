@@ -0,0 +1,27 @@
+CREATE OR REPLACE FUNCTION check_bid_auction_open() RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM products
+        WHERE id = NEW.product_id
+          AND is_sold = false
+          AND auction_end > now()
+    ) THEN
+        RAISE EXCEPTION 'auction for product % has ended', NEW.product_id
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'bids_auction_open';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bids_auction_open
+BEFORE INSERT ON bids
+FOR EACH ROW EXECUTE FUNCTION check_bid_auction_open();
+
+---- create above / drop below ----
+
+DROP TRIGGER IF EXISTS bids_auction_open ON bids;
+DROP FUNCTION IF EXISTS check_bid_auction_open();
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,28 @@
+-- Money is stored as BIGINT in the minor unit of the product currency
+-- (e.g. cents), so amounts are compared and summed exactly.
+ALTER TABLE products
+    ALTER COLUMN baseprice TYPE BIGINT USING round(baseprice * 100)::BIGINT,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
+
+ALTER TABLE bids
+    ALTER COLUMN bid_amount TYPE BIGINT USING round(bid_amount * 100)::BIGINT;
+
+ALTER TABLE auction_results
+    ALTER COLUMN hammer_price TYPE BIGINT USING round(hammer_price * 100)::BIGINT,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
+
+---- create above / drop below ----
+
+ALTER TABLE auction_results
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN hammer_price TYPE FLOAT USING hammer_price / 100.0;
+
+ALTER TABLE bids
+    ALTER COLUMN bid_amount TYPE FLOAT USING bid_amount / 100.0;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN baseprice TYPE FLOAT USING baseprice / 100.0;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
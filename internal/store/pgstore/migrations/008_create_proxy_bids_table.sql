@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS proxy_bids (
+    product_id UUID NOT NULL REFERENCES products(id),
+    bidder_id UUID NOT NULL REFERENCES users(id),
+    max_amount BIGINT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (product_id, bidder_id)
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS proxy_bids;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,12 @@
+ALTER TABLE products
+    ADD COLUMN reserve_price BIGINT,
+    ADD COLUMN buy_now_price BIGINT;
+
+---- create above / drop below ----
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS buy_now_price,
+    DROP COLUMN IF EXISTS reserve_price;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
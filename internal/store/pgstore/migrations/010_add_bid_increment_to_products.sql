@@ -0,0 +1,15 @@
+-- Per product bid increment: either a fixed step in minor units or a
+-- percentage of the current price in basis points. When both are NULL the
+-- global increment ladder applies.
+ALTER TABLE products
+    ADD COLUMN bid_increment BIGINT,
+    ADD COLUMN bid_increment_bps INTEGER;
+
+---- create above / drop below ----
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS bid_increment_bps,
+    DROP COLUMN IF EXISTS bid_increment;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,18 @@
+CREATE TYPE auction_type AS ENUM (
+    'english',
+    'sealed_first_price',
+    'sealed_second_price'
+);
+
+ALTER TABLE products
+    ADD COLUMN auction_type auction_type NOT NULL DEFAULT 'english';
+
+---- create above / drop below ----
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS auction_type;
+
+DROP TYPE IF EXISTS auction_type;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
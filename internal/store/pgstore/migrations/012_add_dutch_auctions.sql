@@ -0,0 +1,21 @@
+ALTER TYPE auction_type ADD VALUE IF NOT EXISTS 'dutch';
+
+-- Dutch auctions start at baseprice when the product is created and drop by
+-- dutch_price_drop every dutch_drop_interval_seconds, never going below
+-- dutch_floor_price.
+ALTER TABLE products
+    ADD COLUMN dutch_floor_price BIGINT,
+    ADD COLUMN dutch_price_drop BIGINT,
+    ADD COLUMN dutch_drop_interval_seconds INTEGER;
+
+---- create above / drop below ----
+
+-- Postgres cannot drop a value from an enum, so 'dutch' stays in
+-- auction_type after rolling back.
+ALTER TABLE products
+    DROP COLUMN IF EXISTS dutch_drop_interval_seconds,
+    DROP COLUMN IF EXISTS dutch_price_drop,
+    DROP COLUMN IF EXISTS dutch_floor_price;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
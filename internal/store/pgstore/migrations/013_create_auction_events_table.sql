@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS auction_events (
+    product_id UUID NOT NULL REFERENCES products(id),
+    seq BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (product_id, seq)
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS auction_events;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
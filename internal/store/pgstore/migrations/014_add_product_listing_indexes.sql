@@ -0,0 +1,14 @@
+-- Product listings filter by seller and auction end, and read the bid count
+-- and highest bid of every product they return.
+CREATE INDEX IF NOT EXISTS products_seller_id_idx ON products (seller_id);
+CREATE INDEX IF NOT EXISTS products_auction_end_idx ON products (auction_end);
+CREATE INDEX IF NOT EXISTS bids_product_id_bid_amount_idx ON bids (product_id, bid_amount DESC);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS bids_product_id_bid_amount_idx;
+DROP INDEX IF EXISTS products_auction_end_idx;
+DROP INDEX IF EXISTS products_seller_id_idx;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,20 @@
+-- The simple configuration neither stems nor drops stop words, so searches
+-- behave the same whatever language the product is described in. Names weigh
+-- more than descriptions when ranking matches.
+ALTER TABLE products
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', product_name), 'A') ||
+        setweight(to_tsvector('simple', description), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS products_search_vector_idx ON products USING GIN (search_vector);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS products_search_vector_idx;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS search_vector;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
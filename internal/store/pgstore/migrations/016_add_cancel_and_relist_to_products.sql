@@ -0,0 +1,52 @@
+-- Cancelled auctions take no more bids and are never settled. A relisted
+-- product points at the auction it was cloned from, which can only be
+-- relisted once.
+ALTER TABLE products
+    ADD COLUMN cancelled_at TIMESTAMPTZ,
+    ADD COLUMN relisted_from UUID REFERENCES products(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_relisted_from_idx ON products (relisted_from);
+
+CREATE OR REPLACE FUNCTION check_bid_auction_open() RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM products
+        WHERE id = NEW.product_id
+          AND is_sold = false
+          AND cancelled_at IS NULL
+          AND auction_end > now()
+    ) THEN
+        RAISE EXCEPTION 'auction for product % has ended', NEW.product_id
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'bids_auction_open';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+---- create above / drop below ----
+
+CREATE OR REPLACE FUNCTION check_bid_auction_open() RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM products
+        WHERE id = NEW.product_id
+          AND is_sold = false
+          AND auction_end > now()
+    ) THEN
+        RAISE EXCEPTION 'auction for product % has ended', NEW.product_id
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'bids_auction_open';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS products_relisted_from_idx;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS relisted_from,
+    DROP COLUMN IF EXISTS cancelled_at;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
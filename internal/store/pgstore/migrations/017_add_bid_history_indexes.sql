@@ -0,0 +1,12 @@
+-- Bid histories are read newest first, page by page, and a user's bidding
+-- activity groups their bids by auction.
+CREATE INDEX IF NOT EXISTS bids_product_id_created_at_idx ON bids (product_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS bids_bidder_id_idx ON bids (bidder_id);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS bids_bidder_id_idx;
+DROP INDEX IF EXISTS bids_product_id_created_at_idx;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,16 @@
+-- A new email only replaces the current one once the user proves they own
+-- it with the token sent there. Only a hash of the token is stored.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS pending_email TEXT,
+    ADD COLUMN IF NOT EXISTS email_token_hash BYTEA,
+    ADD COLUMN IF NOT EXISTS email_token_expires_at TIMESTAMPTZ;
+
+---- create above / drop below ----
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_token_expires_at,
+    DROP COLUMN IF EXISTS email_token_hash,
+    DROP COLUMN IF EXISTS pending_email;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
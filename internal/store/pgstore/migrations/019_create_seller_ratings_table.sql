@@ -0,0 +1,19 @@
+-- The winner of a sold auction rates its seller once.
+CREATE TABLE IF NOT EXISTS seller_ratings (
+    product_id UUID PRIMARY KEY REFERENCES auction_results(product_id),
+    seller_id UUID NOT NULL REFERENCES users(id),
+    buyer_id UUID NOT NULL REFERENCES users(id),
+    score SMALLINT NOT NULL CHECK (score BETWEEN 1 AND 5),
+    comment TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS seller_ratings_seller_id_idx ON seller_ratings (seller_id);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS seller_ratings;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
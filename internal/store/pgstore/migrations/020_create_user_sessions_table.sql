@@ -0,0 +1,18 @@
+-- The sessions table only holds encoded session data, so the sessions a
+-- user logged in with are tracked here to end them all at once, e.g. when
+-- their password changes.
+CREATE TABLE IF NOT EXISTS user_sessions (
+    token TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS user_sessions;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
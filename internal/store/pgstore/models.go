@@ -5,30 +5,124 @@
 package pgstore
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"time"
 
+	"github.com/andresilvase/gobid/internal/money"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuctionType string
+
+const (
+	AuctionTypeEnglish           AuctionType = "english"
+	AuctionTypeSealedFirstPrice  AuctionType = "sealed_first_price"
+	AuctionTypeSealedSecondPrice AuctionType = "sealed_second_price"
+	AuctionTypeDutch             AuctionType = "dutch"
+)
+
+func (e *AuctionType) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AuctionType(s)
+	case string:
+		*e = AuctionType(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AuctionType: %T", src)
+	}
+	return nil
+}
+
+type NullAuctionType struct {
+	AuctionType AuctionType `json:"auction_type"`
+	Valid       bool        `json:"valid"` // Valid is true if AuctionType is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAuctionType) Scan(value interface{}) error {
+	if value == nil {
+		ns.AuctionType, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.AuctionType.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAuctionType) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.AuctionType), nil
+}
+
+type AuctionEvent struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Seq       int64     `json:"seq"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuctionResult struct {
+	ProductID    uuid.UUID     `json:"product_id"`
+	WinnerID     uuid.NullUUID `json:"winner_id"`
+	WinningBidID uuid.NullUUID `json:"winning_bid_id"`
+	HammerPrice  *money.Amount `json:"hammer_price"`
+	IsSold       bool          `json:"is_sold"`
+	EndedAt      time.Time     `json:"ended_at"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Currency     string        `json:"currency"`
+}
+
 type Bid struct {
 	ID        uuid.UUID        `json:"id"`
 	ProductID uuid.UUID        `json:"product_id"`
 	BidderID  uuid.UUID        `json:"bidder_id"`
-	BidAmount float64          `json:"bid_amount"`
+	BidAmount money.Amount     `json:"bid_amount"`
 	CreatedAt pgtype.Timestamp `json:"created_at"`
 }
 
 type Product struct {
-	ID          uuid.UUID `json:"id"`
-	SellerID    uuid.UUID `json:"seller_id"`
-	ProductName string    `json:"product_name"`
-	Description string    `json:"description"`
-	Baseprice   float64   `json:"baseprice"`
-	AuctionEnd  time.Time `json:"auction_end"`
-	IsSold      bool      `json:"is_sold"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                       uuid.UUID          `json:"id"`
+	SellerID                 uuid.UUID          `json:"seller_id"`
+	ProductName              string             `json:"product_name"`
+	Description              string             `json:"description"`
+	Baseprice                money.Amount       `json:"baseprice"`
+	AuctionEnd               time.Time          `json:"auction_end"`
+	IsSold                   bool               `json:"is_sold"`
+	CreatedAt                time.Time          `json:"created_at"`
+	UpdatedAt                time.Time          `json:"updated_at"`
+	Currency                 string             `json:"currency"`
+	ReservePrice             *money.Amount      `json:"reserve_price"`
+	BuyNowPrice              *money.Amount      `json:"buy_now_price"`
+	BidIncrement             *money.Amount      `json:"bid_increment"`
+	BidIncrementBps          pgtype.Int4        `json:"bid_increment_bps"`
+	AuctionType              AuctionType        `json:"auction_type"`
+	DutchFloorPrice          *money.Amount      `json:"dutch_floor_price"`
+	DutchPriceDrop           *money.Amount      `json:"dutch_price_drop"`
+	DutchDropIntervalSeconds pgtype.Int4        `json:"dutch_drop_interval_seconds"`
+	SearchVector             interface{}        `json:"search_vector"`
+	CancelledAt              pgtype.Timestamptz `json:"cancelled_at"`
+	RelistedFrom             uuid.NullUUID      `json:"relisted_from"`
+}
+
+type ProxyBid struct {
+	ProductID uuid.UUID    `json:"product_id"`
+	BidderID  uuid.UUID    `json:"bidder_id"`
+	MaxAmount money.Amount `json:"max_amount"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type SellerRating struct {
+	ProductID uuid.UUID `json:"product_id"`
+	SellerID  uuid.UUID `json:"seller_id"`
+	BuyerID   uuid.UUID `json:"buyer_id"`
+	Score     int16     `json:"score"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Session struct {
@@ -38,11 +132,20 @@ type Session struct {
 }
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	UserName     string    `json:"user_name"`
-	Email        string    `json:"email"`
-	PasswordHash []byte    `json:"password_hash"`
-	Bio          string    `json:"bio"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                  uuid.UUID          `json:"id"`
+	UserName            string             `json:"user_name"`
+	Email               string             `json:"email"`
+	PasswordHash        []byte             `json:"password_hash"`
+	Bio                 string             `json:"bio"`
+	CreatedAt           time.Time          `json:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+	PendingEmail        pgtype.Text        `json:"pending_email"`
+	EmailTokenHash      []byte             `json:"email_token_hash"`
+	EmailTokenExpiresAt pgtype.Timestamptz `json:"email_token_expires_at"`
+}
+
+type UserSession struct {
+	Token     string    `json:"token"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -9,23 +9,48 @@ import (
 	"context"
 	"time"
 
+	"github.com/andresilvase/gobid/internal/money"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const cancelProduct = `-- name: CancelProduct :exec
+UPDATE products
+SET cancelled_at = now(), updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) CancelProduct(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, cancelProduct, id)
+	return err
+}
+
 const createProduct = `-- name: CreateProduct :one
 INSERT INTO products (
     seller_id, product_name, description,
-    baseprice, auction_end
-) VALUES ($1, $2, $3, $4, $5)
+    baseprice, auction_end, currency,
+    reserve_price, buy_now_price,
+    bid_increment, bid_increment_bps, auction_type,
+    dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 RETURNING id
 `
 
 type CreateProductParams struct {
-	SellerID    uuid.UUID `json:"seller_id"`
-	ProductName string    `json:"product_name"`
-	Description string    `json:"description"`
-	Baseprice   float64   `json:"baseprice"`
-	AuctionEnd  time.Time `json:"auction_end"`
+	SellerID                 uuid.UUID     `json:"seller_id"`
+	ProductName              string        `json:"product_name"`
+	Description              string        `json:"description"`
+	Baseprice                money.Amount  `json:"baseprice"`
+	AuctionEnd               time.Time     `json:"auction_end"`
+	Currency                 string        `json:"currency"`
+	ReservePrice             *money.Amount `json:"reserve_price"`
+	BuyNowPrice              *money.Amount `json:"buy_now_price"`
+	BidIncrement             *money.Amount `json:"bid_increment"`
+	BidIncrementBps          pgtype.Int4   `json:"bid_increment_bps"`
+	AuctionType              AuctionType   `json:"auction_type"`
+	DutchFloorPrice          *money.Amount `json:"dutch_floor_price"`
+	DutchPriceDrop           *money.Amount `json:"dutch_price_drop"`
+	DutchDropIntervalSeconds pgtype.Int4   `json:"dutch_drop_interval_seconds"`
 }
 
 func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (uuid.UUID, error) {
@@ -35,6 +60,15 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (u
 		arg.Description,
 		arg.Baseprice,
 		arg.AuctionEnd,
+		arg.Currency,
+		arg.ReservePrice,
+		arg.BuyNowPrice,
+		arg.BidIncrement,
+		arg.BidIncrementBps,
+		arg.AuctionType,
+		arg.DutchFloorPrice,
+		arg.DutchPriceDrop,
+		arg.DutchDropIntervalSeconds,
 	)
 	var id uuid.UUID
 	err := row.Scan(&id)
@@ -42,7 +76,7 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (u
 }
 
 const getProductById = `-- name: GetProductById :one
-SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at FROM products
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, currency, reserve_price, buy_now_price, bid_increment, bid_increment_bps, auction_type, dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds, search_vector, cancelled_at, relisted_from FROM products
 WHERE id = $1
 `
 
@@ -59,6 +93,460 @@ func (q *Queries) GetProductById(ctx context.Context, id uuid.UUID) (Product, er
 		&i.IsSold,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Currency,
+		&i.ReservePrice,
+		&i.BuyNowPrice,
+		&i.BidIncrement,
+		&i.BidIncrementBps,
+		&i.AuctionType,
+		&i.DutchFloorPrice,
+		&i.DutchPriceDrop,
+		&i.DutchDropIntervalSeconds,
+		&i.SearchVector,
+		&i.CancelledAt,
+		&i.RelistedFrom,
+	)
+	return i, err
+}
+
+const getProductByIdForUpdate = `-- name: GetProductByIdForUpdate :one
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, currency, reserve_price, buy_now_price, bid_increment, bid_increment_bps, auction_type, dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds, search_vector, cancelled_at, relisted_from FROM products
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetProductByIdForUpdate(ctx context.Context, id uuid.UUID) (Product, error) {
+	row := q.db.QueryRow(ctx, getProductByIdForUpdate, id)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.SellerID,
+		&i.ProductName,
+		&i.Description,
+		&i.Baseprice,
+		&i.AuctionEnd,
+		&i.IsSold,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.ReservePrice,
+		&i.BuyNowPrice,
+		&i.BidIncrement,
+		&i.BidIncrementBps,
+		&i.AuctionType,
+		&i.DutchFloorPrice,
+		&i.DutchPriceDrop,
+		&i.DutchDropIntervalSeconds,
+		&i.SearchVector,
+		&i.CancelledAt,
+		&i.RelistedFrom,
 	)
 	return i, err
 }
+
+const listOpenAuctions = `-- name: ListOpenAuctions :many
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, currency, reserve_price, buy_now_price, bid_increment, bid_increment_bps, auction_type, dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds, search_vector, cancelled_at, relisted_from FROM products
+WHERE is_sold = false AND cancelled_at IS NULL AND auction_end > now()
+ORDER BY auction_end
+`
+
+func (q *Queries) ListOpenAuctions(ctx context.Context) ([]Product, error) {
+	rows, err := q.db.Query(ctx, listOpenAuctions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Product
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.SellerID,
+			&i.ProductName,
+			&i.Description,
+			&i.Baseprice,
+			&i.AuctionEnd,
+			&i.IsSold,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.ReservePrice,
+			&i.BuyNowPrice,
+			&i.BidIncrement,
+			&i.BidIncrementBps,
+			&i.AuctionType,
+			&i.DutchFloorPrice,
+			&i.DutchPriceDrop,
+			&i.DutchDropIntervalSeconds,
+			&i.SearchVector,
+			&i.CancelledAt,
+			&i.RelistedFrom,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProducts = `-- name: ListProducts :many
+WITH listed AS (
+    SELECT
+        products.id, products.seller_id, products.product_name, products.description,
+        products.baseprice, products.auction_end, products.is_sold,
+        products.created_at, products.updated_at, products.currency,
+        products.reserve_price, products.buy_now_price,
+        products.bid_increment, products.bid_increment_bps, products.auction_type,
+        products.dutch_floor_price, products.dutch_price_drop, products.dutch_drop_interval_seconds,
+        products.cancelled_at,
+        COALESCE(stats.bid_count, 0)::BIGINT AS bid_count,
+        COALESCE(stats.highest_bid, 0)::BIGINT AS highest_bid,
+        COALESCE(CASE
+            WHEN products.auction_type IN ('sealed_first_price', 'sealed_second_price') THEN products.baseprice
+            WHEN stats.highest_bid IS NOT NULL THEN stats.highest_bid
+            WHEN products.auction_type = 'dutch' THEN GREATEST(
+                products.dutch_floor_price,
+                products.baseprice - products.dutch_price_drop * floor(
+                    extract(epoch FROM now() - products.created_at) / products.dutch_drop_interval_seconds
+                )::BIGINT
+            )
+            ELSE products.baseprice
+        END, products.baseprice)::BIGINT AS current_price,
+        COALESCE(ts_rank(products.search_vector, to_tsquery('simple', $1)), 0)::REAL AS rank
+    FROM products
+    LEFT JOIN LATERAL (
+        SELECT COUNT(*) AS bid_count, MAX(bid_amount) AS highest_bid
+        FROM bids
+        WHERE bids.product_id = products.id
+    ) stats ON true
+    WHERE ($1::TEXT IS NULL OR products.search_vector @@ to_tsquery('simple', $1))
+      AND ($2::UUID IS NULL OR products.seller_id = $2)
+      AND ($3::TIMESTAMPTZ IS NULL OR products.auction_end < $3)
+      AND (CASE $4::TEXT
+            WHEN 'active' THEN products.cancelled_at IS NULL AND NOT products.is_sold AND products.auction_end > now()
+            WHEN 'ended' THEN products.cancelled_at IS NULL AND (products.is_sold OR products.auction_end <= now())
+            WHEN 'sold' THEN products.is_sold
+            WHEN 'cancelled' THEN products.cancelled_at IS NOT NULL
+            ELSE true
+          END)
+), sorted AS (
+    SELECT
+        listed.id, listed.seller_id, listed.product_name, listed.description, listed.baseprice, listed.auction_end, listed.is_sold, listed.created_at, listed.updated_at, listed.currency, listed.reserve_price, listed.buy_now_price, listed.bid_increment, listed.bid_increment_bps, listed.auction_type, listed.dutch_floor_price, listed.dutch_price_drop, listed.dutch_drop_interval_seconds, listed.cancelled_at, listed.bid_count, listed.highest_bid, listed.current_price, listed.rank,
+        (CASE $5::TEXT
+            WHEN 'newest' THEN -(extract(epoch FROM listed.created_at) * 1000000)
+            WHEN 'most_bids' THEN -listed.bid_count
+            WHEN 'highest_price' THEN -listed.current_price
+            WHEN 'relevance' THEN -(listed.rank * 1000000)
+            ELSE extract(epoch FROM listed.auction_end) * 1000000
+        END)::BIGINT AS sort_key
+    FROM listed
+    WHERE ($6::TEXT IS NULL OR listed.currency = $6)
+      AND ($7::BIGINT IS NULL OR listed.current_price >= $7)
+      AND ($8::BIGINT IS NULL OR listed.current_price <= $8)
+), page AS (
+    SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, currency, reserve_price, buy_now_price, bid_increment, bid_increment_bps, auction_type, dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds, cancelled_at, bid_count, highest_bid, current_price, rank, sort_key FROM sorted
+    WHERE $9::BIGINT IS NULL
+       OR (sort_key, id) > ($9, $10::UUID)
+    ORDER BY sort_key, id
+    LIMIT $11
+)
+SELECT
+    page.id, page.seller_id, page.product_name, page.description, page.baseprice, page.auction_end, page.is_sold, page.created_at, page.updated_at, page.currency, page.reserve_price, page.buy_now_price, page.bid_increment, page.bid_increment_bps, page.auction_type, page.dutch_floor_price, page.dutch_price_drop, page.dutch_drop_interval_seconds, page.cancelled_at, page.bid_count, page.highest_bid, page.current_price, page.rank, page.sort_key,
+    COALESCE(ts_headline(
+        'simple', page.description, to_tsquery('simple', $1),
+        E'StartSel=\uE000, StopSel=\uE001, MaxFragments=2, MinWords=5, MaxWords=20'
+    ), '')::TEXT AS snippet
+FROM page
+ORDER BY sort_key, id
+`
+
+type ListProductsParams struct {
+	Search       pgtype.Text        `json:"search"`
+	SellerID     uuid.NullUUID      `json:"seller_id"`
+	EndingBefore pgtype.Timestamptz `json:"ending_before"`
+	Status       pgtype.Text        `json:"status"`
+	SortOrder    string             `json:"sort_order"`
+	Currency     pgtype.Text        `json:"currency"`
+	MinPrice     pgtype.Int8        `json:"min_price"`
+	MaxPrice     pgtype.Int8        `json:"max_price"`
+	AfterKey     pgtype.Int8        `json:"after_key"`
+	AfterID      uuid.NullUUID      `json:"after_id"`
+	MaxProducts  int32              `json:"max_products"`
+}
+
+type ListProductsRow struct {
+	ID                       uuid.UUID          `json:"id"`
+	SellerID                 uuid.UUID          `json:"seller_id"`
+	ProductName              string             `json:"product_name"`
+	Description              string             `json:"description"`
+	Baseprice                money.Amount       `json:"baseprice"`
+	AuctionEnd               time.Time          `json:"auction_end"`
+	IsSold                   bool               `json:"is_sold"`
+	CreatedAt                time.Time          `json:"created_at"`
+	UpdatedAt                time.Time          `json:"updated_at"`
+	Currency                 string             `json:"currency"`
+	ReservePrice             *money.Amount      `json:"reserve_price"`
+	BuyNowPrice              *money.Amount      `json:"buy_now_price"`
+	BidIncrement             *money.Amount      `json:"bid_increment"`
+	BidIncrementBps          pgtype.Int4        `json:"bid_increment_bps"`
+	AuctionType              AuctionType        `json:"auction_type"`
+	DutchFloorPrice          *money.Amount      `json:"dutch_floor_price"`
+	DutchPriceDrop           *money.Amount      `json:"dutch_price_drop"`
+	DutchDropIntervalSeconds pgtype.Int4        `json:"dutch_drop_interval_seconds"`
+	CancelledAt              pgtype.Timestamptz `json:"cancelled_at"`
+	BidCount                 int64              `json:"bid_count"`
+	HighestBid               int64              `json:"highest_bid"`
+	CurrentPrice             int64              `json:"current_price"`
+	Rank                     float32            `json:"rank"`
+	SortKey                  int64              `json:"sort_key"`
+	Snippet                  string             `json:"snippet"`
+}
+
+// current_price is what bidders see: the highest bid, the asking price of a
+// dutch auction, or baseprice while sealed bids stay hidden. highest_bid is
+// 0 while there are no bids. search is a
+// tsquery; matches are ranked and their description is highlighted in
+// snippet. sort_key orders the listing ascending for every sort order, so
+// (sort_key, id) is the pagination cursor. Snippets are only made for the
+// page returned.
+func (q *Queries) ListProducts(ctx context.Context, arg ListProductsParams) ([]ListProductsRow, error) {
+	rows, err := q.db.Query(ctx, listProducts,
+		arg.Search,
+		arg.SellerID,
+		arg.EndingBefore,
+		arg.Status,
+		arg.SortOrder,
+		arg.Currency,
+		arg.MinPrice,
+		arg.MaxPrice,
+		arg.AfterKey,
+		arg.AfterID,
+		arg.MaxProducts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProductsRow
+	for rows.Next() {
+		var i ListProductsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SellerID,
+			&i.ProductName,
+			&i.Description,
+			&i.Baseprice,
+			&i.AuctionEnd,
+			&i.IsSold,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.ReservePrice,
+			&i.BuyNowPrice,
+			&i.BidIncrement,
+			&i.BidIncrementBps,
+			&i.AuctionType,
+			&i.DutchFloorPrice,
+			&i.DutchPriceDrop,
+			&i.DutchDropIntervalSeconds,
+			&i.CancelledAt,
+			&i.BidCount,
+			&i.HighestBid,
+			&i.CurrentPrice,
+			&i.Rank,
+			&i.SortKey,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnsettledEndedAuctions = `-- name: ListUnsettledEndedAuctions :many
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, currency, reserve_price, buy_now_price, bid_increment, bid_increment_bps, auction_type, dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds, search_vector, cancelled_at, relisted_from FROM products
+WHERE auction_end <= now()
+  AND cancelled_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM auction_results
+    WHERE auction_results.product_id = products.id
+  )
+ORDER BY auction_end
+`
+
+func (q *Queries) ListUnsettledEndedAuctions(ctx context.Context) ([]Product, error) {
+	rows, err := q.db.Query(ctx, listUnsettledEndedAuctions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Product
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.SellerID,
+			&i.ProductName,
+			&i.Description,
+			&i.Baseprice,
+			&i.AuctionEnd,
+			&i.IsSold,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.ReservePrice,
+			&i.BuyNowPrice,
+			&i.BidIncrement,
+			&i.BidIncrementBps,
+			&i.AuctionType,
+			&i.DutchFloorPrice,
+			&i.DutchPriceDrop,
+			&i.DutchDropIntervalSeconds,
+			&i.SearchVector,
+			&i.CancelledAt,
+			&i.RelistedFrom,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const relistProduct = `-- name: RelistProduct :one
+INSERT INTO products (
+    seller_id, product_name, description,
+    baseprice, auction_end, currency,
+    reserve_price, buy_now_price,
+    bid_increment, bid_increment_bps, auction_type,
+    dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds,
+    relisted_from
+)
+SELECT
+    seller_id, product_name, description,
+    baseprice, $1::TIMESTAMPTZ, currency,
+    reserve_price, buy_now_price,
+    bid_increment, bid_increment_bps, auction_type,
+    dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds,
+    id
+FROM products
+WHERE id = $2
+RETURNING id
+`
+
+type RelistProductParams struct {
+	AuctionEnd time.Time `json:"auction_end"`
+	ID         uuid.UUID `json:"id"`
+}
+
+// The new auction copies everything but the end time; a dutch price clock
+// starts over.
+func (q *Queries) RelistProduct(ctx context.Context, arg RelistProductParams) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, relistProduct, arg.AuctionEnd, arg.ID)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const setProductSold = `-- name: SetProductSold :exec
+UPDATE products
+SET is_sold = $2, updated_at = now()
+WHERE id = $1
+`
+
+type SetProductSoldParams struct {
+	ID     uuid.UUID `json:"id"`
+	IsSold bool      `json:"is_sold"`
+}
+
+func (q *Queries) SetProductSold(ctx context.Context, arg SetProductSoldParams) error {
+	_, err := q.db.Exec(ctx, setProductSold, arg.ID, arg.IsSold)
+	return err
+}
+
+const updateProduct = `-- name: UpdateProduct :one
+UPDATE products
+SET product_name = $2, description = $3,
+    baseprice = $4, reserve_price = $5, buy_now_price = $6,
+    bid_increment = $7, bid_increment_bps = $8,
+    auction_end = $9, updated_at = now()
+WHERE id = $1
+RETURNING id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, currency, reserve_price, buy_now_price, bid_increment, bid_increment_bps, auction_type, dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds, search_vector, cancelled_at, relisted_from
+`
+
+type UpdateProductParams struct {
+	ID              uuid.UUID     `json:"id"`
+	ProductName     string        `json:"product_name"`
+	Description     string        `json:"description"`
+	Baseprice       money.Amount  `json:"baseprice"`
+	ReservePrice    *money.Amount `json:"reserve_price"`
+	BuyNowPrice     *money.Amount `json:"buy_now_price"`
+	BidIncrement    *money.Amount `json:"bid_increment"`
+	BidIncrementBps pgtype.Int4   `json:"bid_increment_bps"`
+	AuctionEnd      time.Time     `json:"auction_end"`
+}
+
+func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (Product, error) {
+	row := q.db.QueryRow(ctx, updateProduct,
+		arg.ID,
+		arg.ProductName,
+		arg.Description,
+		arg.Baseprice,
+		arg.ReservePrice,
+		arg.BuyNowPrice,
+		arg.BidIncrement,
+		arg.BidIncrementBps,
+		arg.AuctionEnd,
+	)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.SellerID,
+		&i.ProductName,
+		&i.Description,
+		&i.Baseprice,
+		&i.AuctionEnd,
+		&i.IsSold,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.ReservePrice,
+		&i.BuyNowPrice,
+		&i.BidIncrement,
+		&i.BidIncrementBps,
+		&i.AuctionType,
+		&i.DutchFloorPrice,
+		&i.DutchPriceDrop,
+		&i.DutchDropIntervalSeconds,
+		&i.SearchVector,
+		&i.CancelledAt,
+		&i.RelistedFrom,
+	)
+	return i, err
+}
+
+const updateProductAuctionEnd = `-- name: UpdateProductAuctionEnd :exec
+UPDATE products
+SET auction_end = $2, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateProductAuctionEndParams struct {
+	ID         uuid.UUID `json:"id"`
+	AuctionEnd time.Time `json:"auction_end"`
+}
+
+func (q *Queries) UpdateProductAuctionEnd(ctx context.Context, arg UpdateProductAuctionEndParams) error {
+	_, err := q.db.Exec(ctx, updateProductAuctionEnd, arg.ID, arg.AuctionEnd)
+	return err
+}
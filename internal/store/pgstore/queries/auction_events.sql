@@ -0,0 +1,20 @@
+-- name: CreateAuctionEvent :one
+INSERT INTO auction_events (
+    product_id, seq, payload
+)
+SELECT $1, COALESCE(MAX(seq), 0) + 1, $2
+FROM auction_events
+WHERE product_id = $1
+RETURNING *;
+
+-- name: GetLastAuctionEventSeq :one
+SELECT COALESCE(MAX(seq), 0)::BIGINT AS seq
+FROM auction_events
+WHERE product_id = $1;
+
+-- name: ListAuctionEventsBetween :many
+-- Lists the newest events first, so a limit keeps the latest ones.
+SELECT * FROM auction_events
+WHERE product_id = @product_id AND seq > @after_seq AND seq <= @until_seq
+ORDER BY seq DESC
+LIMIT @max_events;
@@ -0,0 +1,10 @@
+-- name: CreateAuctionResult :one
+INSERT INTO auction_results (
+    product_id, winner_id, winning_bid_id,
+    hammer_price, is_sold, ended_at, currency
+) VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetAuctionResultByProductId :one
+SELECT * FROM auction_results
+WHERE product_id = $1;
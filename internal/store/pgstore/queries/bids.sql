@@ -9,8 +9,65 @@ SELECT * FROM bids
 WHERE product_id = $1
 ORDER BY bid_amount DESC;
 
+-- name: GetBidStatsByProductId :one
+SELECT COUNT(*) AS bid_count, COUNT(DISTINCT bidder_id) AS bidder_count
+FROM bids
+WHERE product_id = $1;
+
 -- name: GetHighestBidByProductId :one
 SELECT * FROM bids
 WHERE product_id = $1
-ORDER BY bid_amount DESC
+ORDER BY bid_amount DESC, created_at ASC
 LIMIT 1;
+
+-- name: GetTopBidsByProductId :many
+SELECT * FROM bids
+WHERE product_id = $1
+ORDER BY bid_amount DESC, created_at ASC
+LIMIT $2;
+
+-- name: DeleteBidsByProductIdAndBidderId :exec
+DELETE FROM bids
+WHERE product_id = $1 AND bidder_id = $2;
+
+-- name: ListBidsByProductId :many
+-- Bids are listed newest first; (created_at, id) of the last bid of a page
+-- is where the next one starts.
+SELECT * FROM bids
+WHERE product_id = @product_id
+  AND (
+    sqlc.narg(before_created_at)::TIMESTAMP IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at), sqlc.narg(before_id)::UUID)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT @max_bids;
+
+-- name: ListAuctionsByBidderId :many
+-- Every auction bidder_id bid on, with their highest bid, who leads it (the
+-- highest bid, first placed on ties) and its result once settled.
+SELECT
+    products.id, products.product_name, products.auction_type, products.currency,
+    products.auction_end, products.cancelled_at,
+    mine.highest_bid, mine.bid_count, mine.last_bid_at,
+    leader.bidder_id AS leader_id,
+    auction_results.product_id IS NOT NULL AS settled,
+    auction_results.winner_id
+FROM (
+    SELECT
+        product_id,
+        MAX(bid_amount)::BIGINT AS highest_bid,
+        COUNT(*) AS bid_count,
+        MAX(created_at)::TIMESTAMP AS last_bid_at
+    FROM bids
+    WHERE bidder_id = $1
+    GROUP BY product_id
+) AS mine
+JOIN products ON products.id = mine.product_id
+CROSS JOIN LATERAL (
+    SELECT bids.bidder_id FROM bids
+    WHERE bids.product_id = products.id
+    ORDER BY bids.bid_amount DESC, bids.created_at ASC
+    LIMIT 1
+) AS leader
+LEFT JOIN auction_results ON auction_results.product_id = products.id
+ORDER BY products.auction_end DESC, products.id;
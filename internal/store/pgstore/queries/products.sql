@@ -1,10 +1,156 @@
 -- name: CreateProduct :one
 INSERT INTO products (
     seller_id, product_name, description,
-    baseprice, auction_end
-) VALUES ($1, $2, $3, $4, $5)
+    baseprice, auction_end, currency,
+    reserve_price, buy_now_price,
+    bid_increment, bid_increment_bps, auction_type,
+    dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 RETURNING id;
 
 -- name: GetProductById :one
 SELECT * FROM products
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: ListOpenAuctions :many
+SELECT * FROM products
+WHERE is_sold = false AND cancelled_at IS NULL AND auction_end > now()
+ORDER BY auction_end;
+
+-- name: GetProductByIdForUpdate :one
+SELECT * FROM products
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListUnsettledEndedAuctions :many
+SELECT * FROM products
+WHERE auction_end <= now()
+  AND cancelled_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM auction_results
+    WHERE auction_results.product_id = products.id
+  )
+ORDER BY auction_end;
+
+-- name: UpdateProduct :one
+UPDATE products
+SET product_name = $2, description = $3,
+    baseprice = $4, reserve_price = $5, buy_now_price = $6,
+    bid_increment = $7, bid_increment_bps = $8,
+    auction_end = $9, updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelProduct :exec
+UPDATE products
+SET cancelled_at = now(), updated_at = now()
+WHERE id = $1;
+
+-- name: RelistProduct :one
+-- The new auction copies everything but the end time; a dutch price clock
+-- starts over.
+INSERT INTO products (
+    seller_id, product_name, description,
+    baseprice, auction_end, currency,
+    reserve_price, buy_now_price,
+    bid_increment, bid_increment_bps, auction_type,
+    dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds,
+    relisted_from
+)
+SELECT
+    seller_id, product_name, description,
+    baseprice, @auction_end::TIMESTAMPTZ, currency,
+    reserve_price, buy_now_price,
+    bid_increment, bid_increment_bps, auction_type,
+    dutch_floor_price, dutch_price_drop, dutch_drop_interval_seconds,
+    id
+FROM products
+WHERE id = @id
+RETURNING id;
+
+-- name: SetProductSold :exec
+UPDATE products
+SET is_sold = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: UpdateProductAuctionEnd :exec
+UPDATE products
+SET auction_end = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: ListProducts :many
+-- current_price is what bidders see: the highest bid, the asking price of a
+-- dutch auction, or baseprice while sealed bids stay hidden. highest_bid is
+-- 0 while there are no bids. search is a
+-- tsquery; matches are ranked and their description is highlighted in
+-- snippet. sort_key orders the listing ascending for every sort order, so
+-- (sort_key, id) is the pagination cursor. Snippets are only made for the
+-- page returned.
+WITH listed AS (
+    SELECT
+        products.id, products.seller_id, products.product_name, products.description,
+        products.baseprice, products.auction_end, products.is_sold,
+        products.created_at, products.updated_at, products.currency,
+        products.reserve_price, products.buy_now_price,
+        products.bid_increment, products.bid_increment_bps, products.auction_type,
+        products.dutch_floor_price, products.dutch_price_drop, products.dutch_drop_interval_seconds,
+        products.cancelled_at,
+        COALESCE(stats.bid_count, 0)::BIGINT AS bid_count,
+        COALESCE(stats.highest_bid, 0)::BIGINT AS highest_bid,
+        COALESCE(CASE
+            WHEN products.auction_type IN ('sealed_first_price', 'sealed_second_price') THEN products.baseprice
+            WHEN stats.highest_bid IS NOT NULL THEN stats.highest_bid
+            WHEN products.auction_type = 'dutch' THEN GREATEST(
+                products.dutch_floor_price,
+                products.baseprice - products.dutch_price_drop * floor(
+                    extract(epoch FROM now() - products.created_at) / products.dutch_drop_interval_seconds
+                )::BIGINT
+            )
+            ELSE products.baseprice
+        END, products.baseprice)::BIGINT AS current_price,
+        COALESCE(ts_rank(products.search_vector, to_tsquery('simple', sqlc.narg('search'))), 0)::REAL AS rank
+    FROM products
+    LEFT JOIN LATERAL (
+        SELECT COUNT(*) AS bid_count, MAX(bid_amount) AS highest_bid
+        FROM bids
+        WHERE bids.product_id = products.id
+    ) stats ON true
+    WHERE (sqlc.narg('search')::TEXT IS NULL OR products.search_vector @@ to_tsquery('simple', sqlc.narg('search')))
+      AND (sqlc.narg('seller_id')::UUID IS NULL OR products.seller_id = sqlc.narg('seller_id'))
+      AND (sqlc.narg('ending_before')::TIMESTAMPTZ IS NULL OR products.auction_end < sqlc.narg('ending_before'))
+      AND (CASE sqlc.narg('status')::TEXT
+            WHEN 'active' THEN products.cancelled_at IS NULL AND NOT products.is_sold AND products.auction_end > now()
+            WHEN 'ended' THEN products.cancelled_at IS NULL AND (products.is_sold OR products.auction_end <= now())
+            WHEN 'sold' THEN products.is_sold
+            WHEN 'cancelled' THEN products.cancelled_at IS NOT NULL
+            ELSE true
+          END)
+), sorted AS (
+    SELECT
+        listed.*,
+        (CASE @sort_order::TEXT
+            WHEN 'newest' THEN -(extract(epoch FROM listed.created_at) * 1000000)
+            WHEN 'most_bids' THEN -listed.bid_count
+            WHEN 'highest_price' THEN -listed.current_price
+            WHEN 'relevance' THEN -(listed.rank * 1000000)
+            ELSE extract(epoch FROM listed.auction_end) * 1000000
+        END)::BIGINT AS sort_key
+    FROM listed
+    WHERE (sqlc.narg('currency')::TEXT IS NULL OR listed.currency = sqlc.narg('currency'))
+      AND (sqlc.narg('min_price')::BIGINT IS NULL OR listed.current_price >= sqlc.narg('min_price'))
+      AND (sqlc.narg('max_price')::BIGINT IS NULL OR listed.current_price <= sqlc.narg('max_price'))
+), page AS (
+    SELECT * FROM sorted
+    WHERE sqlc.narg('after_key')::BIGINT IS NULL
+       OR (sort_key, id) > (sqlc.narg('after_key'), sqlc.narg('after_id')::UUID)
+    ORDER BY sort_key, id
+    LIMIT @max_products
+)
+SELECT
+    page.*,
+    COALESCE(ts_headline(
+        'simple', page.description, to_tsquery('simple', sqlc.narg('search')),
+        E'StartSel=\uE000, StopSel=\uE001, MaxFragments=2, MinWords=5, MaxWords=20'
+    ), '')::TEXT AS snippet
+FROM page
+ORDER BY sort_key, id;
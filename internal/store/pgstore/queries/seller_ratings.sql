@@ -0,0 +1,18 @@
+-- name: CreateSellerRating :one
+INSERT INTO seller_ratings (
+    product_id, seller_id, buyer_id, score, comment
+) VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetSellerRatingSummary :one
+-- sold_count counts the auctions of the seller that found a buyer.
+SELECT
+    COUNT(*) AS rating_count,
+    COALESCE(AVG(score), 0)::FLOAT AS average_score,
+    (
+        SELECT COUNT(*) FROM auction_results
+        JOIN products ON products.id = auction_results.product_id
+        WHERE products.seller_id = $1 AND auction_results.is_sold
+    ) AS sold_count
+FROM seller_ratings
+WHERE seller_id = $1;
@@ -0,0 +1,18 @@
+-- name: CreateUserSession :exec
+INSERT INTO user_sessions (token, user_id)
+VALUES ($1, $2)
+ON CONFLICT (token) DO UPDATE SET user_id = EXCLUDED.user_id;
+
+-- name: DeleteUserSession :exec
+DELETE FROM user_sessions
+WHERE token = $1;
+
+-- name: DeleteUserSessions :exec
+-- Ends every session of a user, along with their session data.
+WITH ended AS (
+    DELETE FROM user_sessions
+    WHERE user_id = $1
+    RETURNING token
+)
+DELETE FROM sessions
+WHERE token IN (SELECT token FROM ended);
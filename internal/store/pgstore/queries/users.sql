@@ -11,7 +11,8 @@ SELECT
   email,
   bio,
   created_at,
-  updated_at
+  updated_at,
+  pending_email
 FROM
   users
 WHERE
@@ -29,4 +30,45 @@ SELECT
 FROM
   users
 WHERE
-  email = $1;
\ No newline at end of file
+  email = $1;
+
+-- name: GetUserByUserName :one
+SELECT
+  id,
+  user_name,
+  bio,
+  created_at
+FROM
+  users
+WHERE
+  user_name = $1;
+
+-- name: UpdateUserProfile :exec
+UPDATE users
+SET user_name = $2, bio = $3, updated_at = now()
+WHERE id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: SetUserPendingEmail :exec
+UPDATE users
+SET pending_email = $2, email_token_hash = $3, email_token_expires_at = $4, updated_at = now()
+WHERE id = $1;
+
+-- name: ConfirmUserPendingEmail :one
+-- The pending email replaces the current one if the token matches and has
+-- not expired.
+UPDATE users
+SET email = pending_email,
+    pending_email = NULL,
+    email_token_hash = NULL,
+    email_token_expires_at = NULL,
+    updated_at = now()
+WHERE id = $1
+  AND pending_email IS NOT NULL
+  AND email_token_hash = $2
+  AND email_token_expires_at > now()
+RETURNING email;
@@ -10,8 +10,37 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const confirmUserPendingEmail = `-- name: ConfirmUserPendingEmail :one
+UPDATE users
+SET email = pending_email,
+    pending_email = NULL,
+    email_token_hash = NULL,
+    email_token_expires_at = NULL,
+    updated_at = now()
+WHERE id = $1
+  AND pending_email IS NOT NULL
+  AND email_token_hash = $2
+  AND email_token_expires_at > now()
+RETURNING email
+`
+
+type ConfirmUserPendingEmailParams struct {
+	ID             uuid.UUID `json:"id"`
+	EmailTokenHash []byte    `json:"email_token_hash"`
+}
+
+// The pending email replaces the current one if the token matches and has
+// not expired.
+func (q *Queries) ConfirmUserPendingEmail(ctx context.Context, arg ConfirmUserPendingEmailParams) (string, error) {
+	row := q.db.QueryRow(ctx, confirmUserPendingEmail, arg.ID, arg.EmailTokenHash)
+	var email string
+	err := row.Scan(&email)
+	return email, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users ("user_name", "email", "password_hash", "bio")
 VALUES ($1, $2, $3, $4)
@@ -85,7 +114,8 @@ SELECT
   email,
   bio,
   created_at,
-  updated_at
+  updated_at,
+  pending_email
 FROM
   users
 WHERE
@@ -93,13 +123,14 @@ WHERE
 `
 
 type GetUserByIdRow struct {
-	ID           uuid.UUID `json:"id"`
-	UserName     string    `json:"user_name"`
-	PasswordHash []byte    `json:"password_hash"`
-	Email        string    `json:"email"`
-	Bio          string    `json:"bio"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uuid.UUID   `json:"id"`
+	UserName     string      `json:"user_name"`
+	PasswordHash []byte      `json:"password_hash"`
+	Email        string      `json:"email"`
+	Bio          string      `json:"bio"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	PendingEmail pgtype.Text `json:"pending_email"`
 }
 
 func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (GetUserByIdRow, error) {
@@ -113,6 +144,94 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (GetUserByIdRow
 		&i.Bio,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
+
+const getUserByUserName = `-- name: GetUserByUserName :one
+SELECT
+  id,
+  user_name,
+  bio,
+  created_at
+FROM
+  users
+WHERE
+  user_name = $1
+`
+
+type GetUserByUserNameRow struct {
+	ID        uuid.UUID `json:"id"`
+	UserName  string    `json:"user_name"`
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) GetUserByUserName(ctx context.Context, userName string) (GetUserByUserNameRow, error) {
+	row := q.db.QueryRow(ctx, getUserByUserName, userName)
+	var i GetUserByUserNameRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserName,
+		&i.Bio,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const setUserPendingEmail = `-- name: SetUserPendingEmail :exec
+UPDATE users
+SET pending_email = $2, email_token_hash = $3, email_token_expires_at = $4, updated_at = now()
+WHERE id = $1
+`
+
+type SetUserPendingEmailParams struct {
+	ID                  uuid.UUID          `json:"id"`
+	PendingEmail        pgtype.Text        `json:"pending_email"`
+	EmailTokenHash      []byte             `json:"email_token_hash"`
+	EmailTokenExpiresAt pgtype.Timestamptz `json:"email_token_expires_at"`
+}
+
+func (q *Queries) SetUserPendingEmail(ctx context.Context, arg SetUserPendingEmailParams) error {
+	_, err := q.db.Exec(ctx, setUserPendingEmail,
+		arg.ID,
+		arg.PendingEmail,
+		arg.EmailTokenHash,
+		arg.EmailTokenExpiresAt,
+	)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID           uuid.UUID `json:"id"`
+	PasswordHash []byte    `json:"password_hash"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :exec
+UPDATE users
+SET user_name = $2, bio = $3, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateUserProfileParams struct {
+	ID       uuid.UUID `json:"id"`
+	UserName string    `json:"user_name"`
+	Bio      string    `json:"bio"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) error {
+	_, err := q.db.Exec(ctx, updateUserProfile, arg.ID, arg.UserName, arg.Bio)
+	return err
+}
@@ -0,0 +1,31 @@
+package bid
+
+import (
+	"context"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/validator"
+)
+
+type PlaceBidReq struct {
+	Amount *money.Money `json:"amount,omitempty"`
+	// MaxAmount places a proxy bid instead of a bid of a fixed amount.
+	MaxAmount *money.Money `json:"max_amount,omitempty"`
+}
+
+func (req PlaceBidReq) Valid(ctx context.Context) validator.Evaluator {
+	var val validator.Evaluator
+
+	val.CheckField(req.Amount != nil || req.MaxAmount != nil, "amount", "amount or max_amount is required")
+	val.CheckField(req.Amount == nil || req.MaxAmount == nil, "max_amount", "cannot be set together with amount")
+
+	if req.Amount != nil {
+		val.CheckField(req.Amount.IsPositive(), "amount", "this field must be greater than zero")
+	}
+
+	if req.MaxAmount != nil {
+		val.CheckField(req.MaxAmount.IsPositive(), "max_amount", "this field must be greater than zero")
+	}
+
+	return val
+}
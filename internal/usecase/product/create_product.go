@@ -2,19 +2,53 @@ package product
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/store/pgstore"
 	"github.com/andresilvase/gobid/internal/validator"
 )
 
 type CreateProductReq struct {
-	ProductName string    `json:"product_name"`
-	Description string    `json:"description"`
-	Baseprice   float64   `json:"baseprice"`
-	AuctionEnd  time.Time `json:"auction_end"`
+	ProductName string      `json:"product_name"`
+	Description string      `json:"description"`
+	Baseprice   money.Money `json:"baseprice"`
+	AuctionEnd  time.Time   `json:"auction_end"`
+	// ReservePrice is never shown to bidders; the auction ends unsold if the
+	// highest bid does not reach it.
+	ReservePrice *money.Money `json:"reserve_price,omitempty"`
+	BuyNowPrice  *money.Money `json:"buy_now_price,omitempty"`
+	// BidIncrement and BidIncrementBps override the global increment ladder
+	// with a fixed step or a percentage in basis points. Only one may be set.
+	BidIncrement    *money.Money `json:"bid_increment,omitempty"`
+	BidIncrementBps *int32       `json:"bid_increment_bps,omitempty"`
+	// AuctionType defaults to an open ascending (english) auction.
+	AuctionType pgstore.AuctionType `json:"auction_type,omitempty"`
+	// The dutch fields describe the price clock of a dutch auction, which
+	// starts at baseprice and drops on a fixed interval down to the floor.
+	DutchFloorPrice          *money.Money `json:"dutch_floor_price,omitempty"`
+	DutchPriceDrop           *money.Money `json:"dutch_price_drop,omitempty"`
+	DutchDropIntervalSeconds *int32       `json:"dutch_drop_interval_seconds,omitempty"`
+}
+
+// UnmarshalJSON fills in the default auction type when it is omitted.
+func (req *CreateProductReq) UnmarshalJSON(data []byte) error {
+	type createProductReq CreateProductReq
+
+	aux := createProductReq{AuctionType: pgstore.AuctionTypeEnglish}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	*req = CreateProductReq(aux)
+
+	return nil
 }
 
 const minAuctionDuration = 2 * time.Hour
+const minDutchDropInterval = 10
 
 func (req CreateProductReq) Valid(ctx context.Context) validator.Evaluator {
 	var val validator.Evaluator
@@ -25,8 +59,73 @@ func (req CreateProductReq) Valid(ctx context.Context) validator.Evaluator {
 			validator.MaxChars(req.Description, 255),
 		"description", "description must have a length between 10 and 255",
 	)
-	val.CheckField(req.Baseprice > 0, "baseprice", "this field must be greater than zero")
+	val.CheckField(money.IsSupportedCurrency(req.Baseprice.Currency), "baseprice", "currency is not supported")
+	val.CheckField(req.Baseprice.IsPositive(), "baseprice", "this field must be greater than zero")
 	val.CheckField(time.Until(req.AuctionEnd) >= minAuctionDuration, "auction_end", "must to be at least two hours")
 
+	if req.ReservePrice != nil {
+		val.CheckField(req.ReservePrice.SameCurrency(req.Baseprice), "reserve_price", "must use the same currency as baseprice")
+		val.CheckField(req.ReservePrice.Amount >= req.Baseprice.Amount, "reserve_price", "must be greater than or equal to baseprice")
+	}
+
+	if req.BuyNowPrice != nil {
+		val.CheckField(req.BuyNowPrice.SameCurrency(req.Baseprice), "buy_now_price", "must use the same currency as baseprice")
+		val.CheckField(req.BuyNowPrice.Amount > req.Baseprice.Amount, "buy_now_price", "must be greater than baseprice")
+
+		if req.ReservePrice != nil {
+			val.CheckField(req.BuyNowPrice.Amount >= req.ReservePrice.Amount, "buy_now_price", "must be greater than or equal to reserve_price")
+		}
+	}
+
+	if req.BidIncrement != nil {
+		val.CheckField(req.BidIncrementBps == nil, "bid_increment", "cannot be set together with bid_increment_bps")
+		val.CheckField(req.BidIncrement.SameCurrency(req.Baseprice), "bid_increment", "must use the same currency as baseprice")
+		val.CheckField(req.BidIncrement.IsPositive(), "bid_increment", "this field must be greater than zero")
+	}
+
+	val.CheckField(
+		req.AuctionType == pgstore.AuctionTypeEnglish ||
+			req.AuctionType == pgstore.AuctionTypeSealedFirstPrice ||
+			req.AuctionType == pgstore.AuctionTypeSealedSecondPrice ||
+			req.AuctionType == pgstore.AuctionTypeDutch,
+		"auction_type", "must be one of english, sealed_first_price, sealed_second_price or dutch",
+	)
+
+	if req.AuctionType != pgstore.AuctionTypeEnglish {
+		val.CheckField(req.BuyNowPrice == nil, "buy_now_price", "is only available for english auctions")
+	}
+
+	if req.BidIncrementBps != nil {
+		val.CheckField(*req.BidIncrementBps > 0 && *req.BidIncrementBps <= 10000, "bid_increment_bps", "must be between 1 and 10000")
+	}
+
+	if req.AuctionType == pgstore.AuctionTypeDutch {
+		val.CheckField(req.ReservePrice == nil, "reserve_price", "is not available for dutch auctions, use dutch_floor_price")
+		val.CheckField(req.BidIncrement == nil && req.BidIncrementBps == nil, "bid_increment", "is not available for dutch auctions")
+
+		val.CheckField(req.DutchFloorPrice != nil, "dutch_floor_price", "this field is required")
+		val.CheckField(req.DutchPriceDrop != nil, "dutch_price_drop", "this field is required")
+		val.CheckField(req.DutchDropIntervalSeconds != nil, "dutch_drop_interval_seconds", "this field is required")
+
+		if req.DutchFloorPrice != nil {
+			val.CheckField(req.DutchFloorPrice.SameCurrency(req.Baseprice), "dutch_floor_price", "must use the same currency as baseprice")
+			val.CheckField(req.DutchFloorPrice.IsPositive() && req.DutchFloorPrice.Amount < req.Baseprice.Amount, "dutch_floor_price", "must be greater than zero and lower than baseprice")
+		}
+
+		if req.DutchPriceDrop != nil {
+			val.CheckField(req.DutchPriceDrop.SameCurrency(req.Baseprice), "dutch_price_drop", "must use the same currency as baseprice")
+			val.CheckField(req.DutchPriceDrop.IsPositive(), "dutch_price_drop", "this field must be greater than zero")
+		}
+
+		if req.DutchDropIntervalSeconds != nil {
+			val.CheckField(*req.DutchDropIntervalSeconds >= minDutchDropInterval, "dutch_drop_interval_seconds", "must be at least 10 seconds")
+		}
+	} else {
+		val.CheckField(
+			req.DutchFloorPrice == nil && req.DutchPriceDrop == nil && req.DutchDropIntervalSeconds == nil,
+			"auction_type", "dutch fields are only available for dutch auctions",
+		)
+	}
+
 	return val
 }
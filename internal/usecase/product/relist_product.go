@@ -0,0 +1,22 @@
+package product
+
+import (
+	"context"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/validator"
+)
+
+// RelistProductReq starts a new auction of an unsold product, with the same
+// prices and a new end.
+type RelistProductReq struct {
+	AuctionEnd time.Time `json:"auction_end"`
+}
+
+func (req RelistProductReq) Valid(ctx context.Context) validator.Evaluator {
+	var val validator.Evaluator
+
+	val.CheckField(time.Until(req.AuctionEnd) >= minAuctionDuration, "auction_end", "must to be at least two hours")
+
+	return val
+}
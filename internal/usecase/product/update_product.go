@@ -0,0 +1,73 @@
+package product
+
+import (
+	"context"
+	"time"
+
+	"github.com/andresilvase/gobid/internal/money"
+	"github.com/andresilvase/gobid/internal/validator"
+)
+
+// UpdateProductReq edits the fields that are set and leaves the others as
+// they are. Prices must use the currency of the product, which is checked
+// against it when the edit is applied.
+type UpdateProductReq struct {
+	ProductName     *string      `json:"product_name,omitempty"`
+	Description     *string      `json:"description,omitempty"`
+	Baseprice       *money.Money `json:"baseprice,omitempty"`
+	ReservePrice    *money.Money `json:"reserve_price,omitempty"`
+	BuyNowPrice     *money.Money `json:"buy_now_price,omitempty"`
+	BidIncrement    *money.Money `json:"bid_increment,omitempty"`
+	BidIncrementBps *int32       `json:"bid_increment_bps,omitempty"`
+	AuctionEnd      *time.Time   `json:"auction_end,omitempty"`
+}
+
+func (req UpdateProductReq) Valid(ctx context.Context) validator.Evaluator {
+	var val validator.Evaluator
+
+	if req.ProductName != nil {
+		val.CheckField(validator.NotBlank(*req.ProductName), "product_name", "this field cannot be blank")
+	}
+
+	if req.Description != nil {
+		val.CheckField(
+			validator.MinChars(*req.Description, 10) &&
+				validator.MaxChars(*req.Description, 255),
+			"description", "description must have a length between 10 and 255",
+		)
+	}
+
+	if req.Baseprice != nil {
+		val.CheckField(req.Baseprice.IsPositive(), "baseprice", "this field must be greater than zero")
+	}
+
+	if req.ReservePrice != nil {
+		val.CheckField(req.ReservePrice.IsPositive(), "reserve_price", "this field must be greater than zero")
+	}
+
+	if req.BuyNowPrice != nil {
+		val.CheckField(req.BuyNowPrice.IsPositive(), "buy_now_price", "this field must be greater than zero")
+	}
+
+	if req.BidIncrement != nil {
+		val.CheckField(req.BidIncrementBps == nil, "bid_increment", "cannot be set together with bid_increment_bps")
+		val.CheckField(req.BidIncrement.IsPositive(), "bid_increment", "this field must be greater than zero")
+	}
+
+	if req.BidIncrementBps != nil {
+		val.CheckField(*req.BidIncrementBps > 0 && *req.BidIncrementBps <= 10000, "bid_increment_bps", "must be between 1 and 10000")
+	}
+
+	if req.AuctionEnd != nil {
+		val.CheckField(time.Until(*req.AuctionEnd) >= minAuctionDuration, "auction_end", "must to be at least two hours")
+	}
+
+	val.CheckField(
+		req.ProductName != nil || req.Description != nil || req.Baseprice != nil ||
+			req.ReservePrice != nil || req.BuyNowPrice != nil || req.BidIncrement != nil ||
+			req.BidIncrementBps != nil || req.AuctionEnd != nil,
+		"product", "at least one field must be set",
+	)
+
+	return val
+}
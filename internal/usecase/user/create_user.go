@@ -2,10 +2,25 @@ package user
 
 import (
 	"context"
+	"strings"
 
 	"github.com/andresilvase/gobid/internal/validator"
 )
 
+// reservedUserNames cannot be taken, as they stand for routes such as
+// /users/me.
+var reservedUserNames = []string{"me"}
+
+func notReserved(userName string) bool {
+	for _, reserved := range reservedUserNames {
+		if strings.EqualFold(strings.TrimSpace(userName), reserved) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type CreateUserReq struct {
 	UserName string `json:"user_name"`
 	Email    string `json:"email"`
@@ -18,6 +33,7 @@ func (req CreateUserReq) Valid(ctx context.Context) validator.Evaluator {
 	var eval validator.Evaluator
 
 	eval.CheckField(validator.NotBlank(req.UserName), "user_name", "this field is required")
+	eval.CheckField(notReserved(req.UserName), "user_name", "this user name is reserved")
 
 	eval.CheckField(validator.NotBlank(req.Email), "email", "this field is required")
 
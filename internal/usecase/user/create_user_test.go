@@ -0,0 +1,56 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andresilvase/gobid/internal/validator"
+)
+
+func TestNotReserved(t *testing.T) {
+	tests := []struct {
+		userName string
+		want     bool
+	}{
+		{userName: "me", want: false},
+		{userName: "Me", want: false},
+		{userName: " ME ", want: false},
+		{userName: "meg", want: true},
+		{userName: "andre", want: true},
+		{userName: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userName, func(t *testing.T) {
+			if got := notReserved(tt.userName); got != tt.want {
+				t.Errorf("notReserved(%q) = %v, want %v", tt.userName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservedUserNameIsRejected(t *testing.T) {
+	me := "me"
+
+	tests := []struct {
+		name string
+		req  validator.Validator
+	}{
+		{
+			name: "signup",
+			req:  CreateUserReq{UserName: me, Email: "me@example.com", Password: "password", Bio: "a long enough bio"},
+		},
+		{
+			name: "update",
+			req:  UpdateUserReq{UserName: &me},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := tt.req.Valid(context.Background())["user_name"]; !ok {
+				t.Errorf("user name %q was accepted", me)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package user
+
+import (
+	"context"
+
+	"github.com/andresilvase/gobid/internal/validator"
+)
+
+type RateSellerReq struct {
+	Score   int16  `json:"score"`
+	Comment string `json:"comment"`
+}
+
+func (req RateSellerReq) Valid(ctx context.Context) validator.Evaluator {
+	var eval validator.Evaluator
+
+	eval.CheckField(req.Score >= 1 && req.Score <= 5, "score", "must be between 1 and 5")
+	eval.CheckField(validator.MaxChars(req.Comment, 255), "comment", "this field must have at most 255 chars")
+
+	return eval
+}
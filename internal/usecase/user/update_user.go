@@ -0,0 +1,53 @@
+package user
+
+import (
+	"context"
+
+	"github.com/andresilvase/gobid/internal/validator"
+)
+
+// UpdateUserReq changes the fields that are set. A new email or password
+// requires the current password.
+type UpdateUserReq struct {
+	UserName        *string `json:"user_name,omitempty"`
+	Bio             *string `json:"bio,omitempty"`
+	Email           *string `json:"email,omitempty"`
+	NewPassword     *string `json:"new_password,omitempty"`
+	CurrentPassword string  `json:"current_password,omitempty"`
+}
+
+func (req UpdateUserReq) Valid(ctx context.Context) validator.Evaluator {
+	var eval validator.Evaluator
+
+	if req.UserName != nil {
+		eval.CheckField(validator.NotBlank(*req.UserName), "user_name", "this field cannot be blank")
+		eval.CheckField(validator.MaxChars(*req.UserName, 50), "user_name", "this field must have at most 50 chars")
+		eval.CheckField(notReserved(*req.UserName), "user_name", "this user name is reserved")
+	}
+
+	if req.Bio != nil {
+		eval.CheckField(
+			validator.MinChars(*req.Bio, 10) && validator.MaxChars(*req.Bio, 255),
+			"bio", "this field must have a length between 10 and 255",
+		)
+	}
+
+	if req.Email != nil {
+		eval.CheckField(validator.Matches(*req.Email, validator.EmailRx), "email", "this field must be a valid email")
+	}
+
+	if req.NewPassword != nil {
+		eval.CheckField(validator.MinChars(*req.NewPassword, 8), "new_password", "this field must have at least 8 chars")
+	}
+
+	if req.Email != nil || req.NewPassword != nil {
+		eval.CheckField(validator.NotBlank(req.CurrentPassword), "current_password", "this field is required to change the email or password")
+	}
+
+	eval.CheckField(
+		req.UserName != nil || req.Bio != nil || req.Email != nil || req.NewPassword != nil,
+		"user", "at least one field must be set",
+	)
+
+	return eval
+}
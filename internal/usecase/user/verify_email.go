@@ -0,0 +1,19 @@
+package user
+
+import (
+	"context"
+
+	"github.com/andresilvase/gobid/internal/validator"
+)
+
+type VerifyEmailReq struct {
+	Token string `json:"token"`
+}
+
+func (req VerifyEmailReq) Valid(ctx context.Context) validator.Evaluator {
+	var eval validator.Evaluator
+
+	eval.CheckField(validator.NotBlank(req.Token), "token", "this field is required")
+
+	return eval
+}